@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nutmegdevelopment/marathon-client/marathon"
+)
+
+//
+// Deploying and deleting jobs
+//
+
+// deployOptions are the flags of the deploy and delete commands.
+type deployOptions struct {
+	file      string
+	smokeFile string
+	delete    bool
+	dryRun    bool
+	skipSame  bool
+	stampHash bool
+	rollback  bool
+
+	conflictWait time.Duration
+
+	stableFor     time.Duration
+	healthyMin    int
+	healthyWithin time.Duration
+}
+
+func deployFlags(fs *flag.FlagSet, delete bool) *deployOptions {
+
+	o := &deployOptions{delete: delete}
+
+	fs.StringVar(&o.file, "f", "", "Job file, or - to read from stdin")
+	fs.DurationVar(&o.conflictWait, "max-conflict-wait", 10*time.Minute, "How long to wait for deployments blocking this one to finish (0 waits forever)")
+
+	if delete {
+		fs.BoolVar(&o.dryRun, "dry-run", false, "Check the job exists without deleting it, exits 2 if it does")
+		return o
+	}
+
+	fs.BoolVar(&o.dryRun, "dry-run", false, "Show the changes that would be made without deploying, exits 2 if there are any")
+	fs.BoolVar(&o.rollback, "rollback", false, "Roll back the deployment if it fails or times out")
+	fs.BoolVar(&o.skipSame, "skip-unchanged", false, "Don't deploy if the job matches what is already running")
+	fs.BoolVar(&o.stampHash, "hash", false, "Label the job with a hash of its definition, used by -skip-unchanged")
+	fs.DurationVar(&o.stableFor, "stable-for", 0, "After deploying, check the apps stay healthy on the new version for this long, e.g. 1m (0 to skip)")
+	fs.IntVar(&o.healthyMin, "healthy-instances", 0, "Healthy instances each app needs for -stable-for (0 for all of them)")
+	fs.DurationVar(&o.healthyWithin, "healthy-within", 0, "Fail if the apps aren't healthy this long after deploying, for -stable-for (0 for the -stable-for time)")
+	fs.StringVar(&o.smokeFile, "smoke", "", "File of smoke checks to run against the tasks once deployed, in addition to any in the job file")
+
+	return o
+}
+
+func runDeploy(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	trk := trackingFlags(fs)
+	opts := deployFlags(fs, false)
+	fs.Parse(args)
+
+	deployJob(conn, trk, opts)
+}
+
+func runDelete(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	trk := trackingFlags(fs)
+	opts := deployFlags(fs, true)
+	fs.Parse(args)
+
+	deployJob(conn, trk, opts)
+}
+
+// runLegacy runs deploy with the flags used before there were commands,
+// where -delete deletes the job instead.
+func runLegacy(name string, args []string) {
+	deployJob(legacyFlags(name, args))
+}
+
+// legacyFlags parses the flags of the original form.
+func legacyFlags(name string, args []string) (*connection, *tracking, *deployOptions) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	conn := connectionFlags(fs)
+	trk := trackingFlags(fs)
+	opts := deployFlags(fs, false)
+	fs.BoolVar(&opts.delete, "delete", false, "Delete an existing application, use the delete command instead")
+	fs.Parse(args)
+
+	return conn, trk, opts
+}
+
+func runDiff(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	var file string
+	fs.StringVar(&file, "f", "", "Job file, or - to read from stdin")
+	fs.Parse(args)
+
+	client := conn.client()
+
+	_, job := readJob(file, "")
+
+	root, stop := conn.signalContext()
+	defer stop()
+
+	ctx, cancel := conn.withTimeout(root)
+	defer cancel()
+
+	code := showChanges(ctx, client, job, false)
+	cancel()
+	os.Exit(code)
+}
+
+// readJob reads a job file and any smoke checks, exiting on any error.
+func readJob(file, smokeFile string) ([]marathon.SmokeCheck, marathon.Job) {
+
+	if file == "" {
+		log.Fatal("Marathon job (-f) is required")
+	}
+
+	var data []byte
+	var err error
+
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	checks, data, err := marathon.SplitSmokeChecks(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if smokeFile != "" {
+		sidecar, err := ioutil.ReadFile(smokeFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		more, _, err := marathon.SplitSmokeChecks(sidecar)
+		if err != nil {
+			log.Fatal(err)
+		}
+		checks = append(checks, more...)
+	}
+
+	job, err := marathon.NewJob(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return checks, job
+}
+
+// deployJob deploys or deletes a job, tracks the deployment, and rolls it
+// back if asked to.  It exits with status 1 if the deployment fails.
+func deployJob(conn *connection, trk *tracking, opts *deployOptions) {
+
+	client := conn.client()
+	trk.configure(client)
+
+	checks, job := readJob(opts.file, opts.smokeFile)
+
+	root, stop := conn.signalContext()
+	defer stop()
+
+	ctx, cancel := conn.withTimeout(root)
+	defer cancel()
+
+	if opts.stampHash && !opts.delete {
+		err := job.StampHash()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if opts.dryRun {
+		code := showChanges(ctx, client, job, opts.delete)
+		cancel()
+		os.Exit(code)
+	}
+
+	if opts.skipSame && !opts.delete {
+		unchanged, err := client.Unchanged(ctx, job)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if unchanged {
+			log.Println("Deployment unchanged, nothing to do")
+			return
+		}
+	}
+
+	// A job created by a failed deployment is deleted to roll it back
+	var created bool
+	if opts.rollback && !opts.delete {
+		live, err := client.LiveDefinition(ctx, job)
+		if err != nil {
+			log.Fatal(err)
+		}
+		created = live == nil
+	}
+
+	t := trk.start(ctx, client)
+
+	// Wait out any deployments in the way on the same stream
+	client.Events = t.events
+	client.MaxConflictWait = opts.conflictWait
+
+	// Create the deployment job
+	var id string
+	var err error
+	if opts.delete {
+		id, err = client.DeleteApplication(ctx, job, trk.force)
+	} else {
+		id, err = client.DeployApplication(ctx, job, trk.force)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dur, err := t.track(ctx, id, job)
+
+	// Marathon can report success while apps are still flapping
+	if err == nil && opts.stableFor > 0 && !opts.delete {
+		log.Println("Deployment finished, checking the apps stay healthy for", opts.stableFor)
+		err = client.WaitStable(ctx, job, marathon.Stability{
+			Instances: opts.healthyMin,
+			Window:    opts.stableFor,
+			Grace:     opts.healthyWithin,
+			Interval:  trk.pollInterval,
+		}, t.events)
+	}
+
+	if err == nil && len(checks) > 0 && !opts.delete {
+		log.Println("Running smoke tests")
+		err = client.SmokeTest(ctx, job, checks)
+	}
+
+	if report("Deployment", dur, err) {
+		return
+	}
+
+	// Interrupted by the user
+	if root.Err() != nil || !opts.rollback || opts.delete {
+		os.Exit(1)
+	}
+
+	// Close the first stream before opening another
+	t.stop()
+	cancel()
+
+	// The deployment may have used up the timeout, so start again
+	ctx, cancel = conn.withTimeout(root)
+	defer cancel()
+
+	t = trk.start(ctx, client)
+	client.Events = t.events
+
+	log.Println("Rolling back")
+
+	id, err = client.Rollback(ctx, job, id, created)
+	if err != nil {
+		log.Println("Rollback failed:", err)
+		os.Exit(1)
+	}
+
+	dur, err = t.track(ctx, id, job)
+	report("Rollback", dur, err)
+	os.Exit(1)
+}
+
+// showChanges prints what deploying or deleting job would change, and
+// returns the exit code: 0 if nothing would change, 2 otherwise.
+func showChanges(ctx context.Context, client *marathon.Client, job marathon.Job, delete bool) int {
+
+	if delete {
+		live, err := client.LiveDefinition(ctx, job)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if live == nil {
+			log.Fatal("Job does not exist, cannot delete")
+		}
+		fmt.Println("- " + job.Id())
+		return 2
+	}
+
+	changes, err := client.Diff(ctx, job)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(changes) == 0 {
+		log.Println("No changes")
+		return 0
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	return 2
+}
@@ -1,199 +1,170 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"log"
+	"os"
 	"strings"
-	"time"
+
+	"github.com/nutmegdevelopment/marathon-client/marathon"
 )
 
 //
-// Marathon API support
+// Watching the event stream
 //
 
-// Raw event from the SSE stream
-type RawEvent struct {
-	Name string
-	Data []byte
-}
-
-type Timestamp string
-
-func (t Timestamp) String() string {
-	parsed, err := time.Parse(time.RFC3339, string(t))
-	if err != nil {
-		return ""
+func runEvents(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	var types, states, until string
+	var filter marathon.EventFilter
+	var raw bool
+	fs.StringVar(&types, "type", "", "Comma separated event types to show, e.g. deployment_success,deployment_failed (all if empty)")
+	fs.StringVar(&filter.AppPrefix, "app", "", "Only show events for apps, pods and groups with IDs starting with this")
+	fs.StringVar(&filter.DeploymentId, "deployment", "", "Only show events for this deployment ID")
+	fs.StringVar(&states, "task-state", "", "Comma separated task states to show, e.g. TASK_FAILED,TASK_KILLED (all if empty)")
+	fs.BoolVar(&raw, "json", false, "Print each event as a line of JSON")
+	fs.StringVar(&until, "until", "", "Stop after the first event of this type, optionally for an app ID prefix, e.g. deployment_success:/foo")
+	fs.Parse(args)
+
+	arguments(fs, 0)
+
+	filter.Types = splitList(types)
+	filter.TaskStates = splitList(states)
+
+	var stop *marathon.EventFilter
+	if until != "" {
+		parts := strings.SplitN(until, ":", 2)
+		stop = &marathon.EventFilter{Types: parts[:1]}
+		if len(parts) == 2 {
+			stop.AppPrefix = parts[1]
+		}
 	}
-	return parsed.String()
-}
 
-func (t Timestamp) Time() time.Time {
-	parsed, err := time.Parse(time.RFC3339, string(t))
-	if err != nil {
-		return time.Time{}
-	}
-	return parsed
-}
+	client := conn.client()
 
-// Event is the main event container
-type Event struct {
-	Name                   string
-	ApiPostEvent           ApiPostEvent
-	AddHealthCheck         AddHealthCheck
-	FailedHealthCheck      FailedHealthCheck
-	HealthStatusChanged    HealthStatusChanged
-	GroupChangeSuccess     GroupChangeSuccess
-	GroupChangeFailed      GroupChangeFailed
-	DeploymentStatus       DeploymentStatus
-	MesosStatusUpdateEvent MesosStatusUpdateEvent
-}
+	root, cancelRoot := conn.signalContext()
+	defer cancelRoot()
 
-type EventCommon struct {
-	EventType string
-	Timestamp Timestamp
-}
+	ctx, cancel := conn.withTimeout(root)
+	defer cancel()
 
-func (e EventCommon) Type() string {
-	return e.EventType
-}
+	events := make(chan marathon.RawEvent, 64)
 
-func (e EventCommon) Date() time.Time {
-	return e.Timestamp.Time()
-}
-
-// POST event
-type ApiPostEvent struct {
-	ClientIp      string
-	Uri           string
-	AppDefinition map[string]interface{}
-	EventCommon
-}
-
-// Health check events
-type AddHealthCheck struct {
-	AppId       string
-	Version     Timestamp
-	HealthCheck map[string]interface{}
-	EventCommon
-}
-
-type FailedHealthCheck struct {
-	AppId       string
-	TaskId      string
-	HealthCheck map[string]interface{}
-	EventCommon
-}
-
-type HealthStatusChanged struct {
-	AppId   string
-	TaskId  string
-	Version Timestamp
-	Alive   bool
-	EventCommon
-}
+	err := client.EventListener(ctx, events, listenTypes(filter, stop)...)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-// Group events
-type GroupChangeSuccess struct {
-	GroupId string
-	Version string
-	EventCommon
-}
+	for e := range events {
 
-type GroupChangeFailed struct {
-	GroupId string
-	Version string
-	Reason  string
-	EventCommon
-}
+		s, err := marathon.SummariseEvent(e)
+		if err != nil {
+			log.Println("Error parsing event:", err, string(e.Data))
+			continue
+		}
 
-type Action struct {
-	Action string
-	App    string
-}
+		if s.Type == marathon.ReconnectedEvent {
+			log.Println("Event stream reconnected, events may have been missed")
+			continue
+		}
 
-type DeploymentPlan struct {
-	Id       string
-	Original map[string]interface{}
-	Target   map[string]interface{}
-	Steps    []Action
-	Version  Timestamp
-}
+		if filter.Match(s) {
+			if raw {
+				printJSON(e)
+			} else {
+				fmt.Println(formatEvent(s))
+			}
+		}
 
-type DeploymentStatus struct {
-	Id          string
-	Plan        DeploymentPlan
-	CurrentStep struct {
-		Actions []struct {
-			Type string
-			App  string
+		if stop != nil && stop.Match(s) {
+			return
 		}
 	}
-	EventCommon
-}
 
-// Mesos events
-type MesosStatusUpdateEvent struct {
-	SlaveId    string
-	TaskId     string
-	TaskStatus string
-	Message    string
-	AppId      string
-	Host       string
-	Ports      []int
-	Version    string
-	EventCommon
+	// Only reached if the stream ended before the -until condition
+	if stop != nil {
+		if root.Err() == nil && ctx.Err() != nil {
+			log.Println("Timed out waiting for", until)
+		}
+		cancel()
+		os.Exit(1)
+	}
 }
 
-func (e *Event) Unmarshal(in RawEvent) (err error) {
+// listenTypes gives the event types to ask the server for, which must
+// include those needed to match deployments to apps.
+func listenTypes(filter marathon.EventFilter, stop *marathon.EventFilter) []string {
 
-	if in.Name == "" || len(in.Data) == 0 {
-		return errors.New("Bad event object")
+	if len(filter.Types) == 0 {
+		return nil
 	}
 
-	e.Name = strings.TrimRight(in.Name, "\r\n")
+	types := append([]string{}, filter.Types...)
 
-	switch e.Name {
-
-	case "api_post_event":
-		err = json.Unmarshal(in.Data, &e.ApiPostEvent)
-
-	case "add_health_check_event":
-		err = json.Unmarshal(in.Data, &e.AddHealthCheck)
-
-	case "failed_health_check_event":
-		err = json.Unmarshal(in.Data, &e.FailedHealthCheck)
-
-	case "health_status_changed_event":
-		err = json.Unmarshal(in.Data, &e.HealthStatusChanged)
+	if filter.AppPrefix != "" {
+		types = append(types, "deployment_info")
+	}
 
-	case "group_change_success":
-		err = json.Unmarshal(in.Data, &e.GroupChangeSuccess)
+	if stop != nil {
+		types = append(types, stop.Types...)
+		if stop.AppPrefix != "" {
+			types = append(types, "deployment_info")
+		}
+	}
 
-	case "group_change_failed":
-		err = json.Unmarshal(in.Data, &e.GroupChangeFailed)
+	return types
+}
 
-	case "deployment_success":
-		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
 
-	case "deployment_failed":
-		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+// printJSON prints the event as it was sent, on one line.
+func printJSON(e marathon.RawEvent) {
 
-	case "deployment_info":
-		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+	var buf bytes.Buffer
+	err := json.Compact(&buf, e.Data)
+	if err != nil {
+		log.Println("Error parsing event:", err, string(e.Data))
+		return
+	}
 
-	case "deployment_step_success":
-		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+	fmt.Println(buf.String())
+}
 
-	case "deployment_step_failure":
-		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+func formatEvent(s marathon.EventSummary) string {
 
-	case "status_update_event":
-		err = json.Unmarshal(in.Data, &e.MesosStatusUpdateEvent)
+	var fields []string
 
-	default:
-		err = errors.New("Unhandled event")
+	if s.Timestamp != "" {
+		fields = append(fields, string(s.Timestamp))
+	}
+	fields = append(fields, s.Type)
 
+	if len(s.Apps) > 0 {
+		fields = append(fields, strings.Join(s.Apps, ","))
+	}
+	if s.DeploymentId != "" {
+		fields = append(fields, "deployment="+s.DeploymentId)
+	}
+	if s.TaskId != "" {
+		fields = append(fields, "task="+s.TaskId)
+	}
+	if s.TaskStatus != "" {
+		fields = append(fields, s.TaskStatus)
+	}
+	if s.Host != "" {
+		fields = append(fields, "host="+s.Host)
+	}
+	if s.Message != "" {
+		fields = append(fields, fmt.Sprintf("%q", s.Message))
 	}
 
-	return
+	return strings.Join(fields, " ")
 }
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nutmegdevelopment/marathon-client/marathon"
+)
+
+//
+// Looking at what is running
+//
+
+func runStatus(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	fs.Parse(args)
+
+	id := arguments(fs, 1)[0]
+
+	client := conn.client()
+
+	ctx, cancel := conn.withTimeout(context.Background())
+	defer cancel()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	app, err := client.AppStatus(ctx, id)
+	if err == nil {
+		printApp(w, app)
+		return
+	}
+	if _, ok := err.(*marathon.NotFoundError); !ok {
+		log.Fatal(err)
+	}
+
+	pod, err := client.PodStatus(ctx, id)
+	if err == nil {
+		printPod(w, pod)
+		return
+	}
+	if _, ok := err.(*marathon.NotFoundError); !ok {
+		log.Fatal(err)
+	}
+
+	group, err := client.Group(ctx, id)
+	if err != nil {
+		if _, ok := err.(*marathon.NotFoundError); ok {
+			log.Fatal("No application, pod or group found with ID ", id)
+		}
+		log.Fatal(err)
+	}
+
+	job := marathon.Job{Group: group}
+	for i, appId := range job.Apps() {
+		app, err := client.AppStatus(ctx, appId)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		printApp(w, app)
+	}
+}
+
+func printApp(w *tabwriter.Writer, app *marathon.AppStatus) {
+
+	fmt.Fprintf(w, "Application:\t%s\n", app.Id)
+	fmt.Fprintf(w, "Version:\t%s\n", string(app.Version))
+	fmt.Fprintf(w, "Instances:\t%d running, %d staged, %d healthy, %d unhealthy, of %d\n",
+		app.TasksRunning, app.TasksStaged, app.TasksHealthy, app.TasksUnhealthy, app.Instances)
+
+	for _, d := range app.Deployments {
+		fmt.Fprintf(w, "Deployment:\t%s\n", d.Id)
+	}
+
+	if f := app.LastTaskFailure; f != nil {
+		fmt.Fprintf(w, "Last failure:\t%s %s on %s at %s\n", f.State, f.Message, f.Host, string(f.Timestamp))
+	}
+}
+
+func printPod(w *tabwriter.Writer, pod *marathon.PodStatus) {
+
+	fmt.Fprintf(w, "Pod:\t%s\n", pod.Id)
+	fmt.Fprintf(w, "Status:\t%s\n", pod.Status)
+
+	for _, i := range pod.Instances {
+		fmt.Fprintf(w, "Instance:\t%s %s on %s\n", i.Id, i.Status, i.AgentHostname)
+	}
+}
+
+func runTasks(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	fs.Parse(args)
+
+	id := arguments(fs, 1)[0]
+
+	client := conn.client()
+
+	ctx, cancel := conn.withTimeout(context.Background())
+	defer cancel()
+
+	tasks, err := client.Tasks(ctx, id)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "ID\tHOST\tPORTS\tSTATE\tHEALTHY\tVERSION\tSTARTED")
+
+	for _, t := range tasks {
+
+		ports := make([]string, len(t.Ports))
+		for i, p := range t.Ports {
+			ports[i] = fmt.Sprint(p)
+		}
+
+		healthy := "-"
+		if len(t.HealthCheckResults) > 0 {
+			healthy = fmt.Sprint(t.Healthy())
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.Id, t.Host, strings.Join(ports, ","), t.State, healthy, string(t.Version), string(t.StartedAt))
+	}
+}
+
+func runDeployments(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	fs.Parse(args)
+
+	arguments(fs, 0)
+
+	client := conn.client()
+
+	ctx, cancel := conn.withTimeout(context.Background())
+	defer cancel()
+
+	list, err := client.Deployments(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "ID\tAFFECTS\tSTEP\tAGE")
+
+	for _, d := range list {
+
+		age := "-"
+		if d.Age() > 0 {
+			age = d.Age().String()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\n", d.Id, strings.Join(d.Affects(), ","), d.CurrentStep, d.TotalSteps, age)
+	}
+}
+
+func runDeployment(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	fs.Parse(args)
+
+	id := arguments(fs, 1)[0]
+
+	client := conn.client()
+
+	ctx, cancel := conn.withTimeout(context.Background())
+	defer cancel()
+
+	d, err := client.Deployment(ctx, id)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Deployment:\t%s\n", d.Id)
+	fmt.Fprintf(w, "Version:\t%s\n", string(d.Version))
+	if d.Age() > 0 {
+		fmt.Fprintf(w, "Age:\t%s\n", d.Age())
+	}
+	fmt.Fprintf(w, "Affects:\t%s\n", strings.Join(d.Affects(), ", "))
+
+	for i, step := range d.Steps {
+
+		state := ""
+		switch {
+		case i+1 < d.CurrentStep:
+			state = "done"
+		case i+1 == d.CurrentStep:
+			state = "in progress"
+		}
+
+		for j, a := range step.Actions {
+			label := ""
+			if j == 0 {
+				label = fmt.Sprintf("Step %d/%d:", i+1, len(d.Steps))
+			}
+			fmt.Fprintf(w, "%s\t%s %s\t%s\n", label, a.Action, a.Target(), state)
+			state = ""
+		}
+	}
+}
@@ -1,183 +1,285 @@
 package main // import "github.com/nutmegdevelopment/marathon-client"
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
-)
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-var (
-	rawurl, file string
-	user, pass   string
-	debug        bool
-	authenticate bool
-	force        bool
-	delete       bool
+	"github.com/nutmegdevelopment/marathon-client/marathon"
 )
 
+// command is a subcommand of the client.
+type command struct {
+	name string
+	args string
+	help string
+	run  func(name string, args []string)
+}
+
+var commands []command
+
+// legacy runs the original form of the client, flags with no command.
+var legacy command
+
 func init() {
-	flag.StringVar(&rawurl, "m", "", "Marathon URL")
-	flag.StringVar(&file, "f", "", "Job file")
-	flag.StringVar(&user, "u", "", "Username for basic auth")
-	flag.StringVar(&pass, "p", "", "Password for basic auth")
-	flag.BoolVar(&debug, "d", false, "Debug output")
-	flag.BoolVar(&force, "force", false, "Force deploy over any existing deployments")
-	flag.BoolVar(&delete, "delete", false, "Delete an existing application")
-	flag.Parse()
-
-	if user != "" && pass != "" {
-		authenticate = true
+	legacy = command{"marathon-client", "-f job.json", "Deploy, or delete with -delete, a job", runLegacy}
+	commands = []command{
+		{"deploy", "-f job.json", "Create or update an application, group or pod, and track the deployment", runDeploy},
+		{"delete", "-f job.json", "Delete an application, group or pod, and track the deployment", runDelete},
+		{"diff", "-f job.json", "Show what deploying a job would change", runDiff},
+		{"status", "<id>", "Show the state of an application, group or pod", runStatus},
+		{"tasks", "<app id>", "List the tasks of an application", runTasks},
+		{"scale", "<id> <instances>", "Change the number of instances of an application or pod, or scale a group with -group", runScale},
+		{"restart", "<app id>", "Restart every task of an application", runRestart},
+		{"deployments", "", "List the deployments in progress", runDeployments},
+		{"deployment", "<deployment id>", "Show the plan of a deployment in progress", runDeployment},
+		{"cancel", "<deployment id>", "Cancel a deployment in progress, rolling back its changes unless -no-rollback is given", runCancel},
+		{"events", "", "Tail and filter the Marathon event stream", runEvents},
 	}
 }
 
-func eventBus(in <-chan RawEvent, out chan<- Event) {
-
-	defer close(out)
+func usage() {
+	w := os.Stderr
+	fmt.Fprintln(w, "Usage: marathon-client <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.help)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'marathon-client <command> -h' for the flags of a command.")
+	fmt.Fprintln(w, "Flags without a command, e.g. 'marathon-client -m url -f job.json', run deploy.")
+}
 
-	for {
+func main() {
 
-		select {
+	cmd, args := lookup(os.Args[1:])
+	if cmd != nil {
+		cmd.run(cmd.name, args)
+		return
+	}
 
-		case in, ok := <-in:
-			if !ok {
-				break
-			}
+	if args[0] != "help" {
+		fmt.Fprintln(os.Stderr, "Unknown command:", args[0])
+		fmt.Fprintln(os.Stderr)
+	}
+	usage()
+	os.Exit(2)
+}
 
-			e := new(Event)
-			err := e.Unmarshal(in)
+// lookup finds the command to run for the arguments, and the arguments to
+// pass it.  nil is returned if there is no such command.
+func lookup(args []string) (*command, []string) {
 
-			switch {
+	// The original form, flags with no command
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return &legacy, args
+	}
 
-			case err != nil && err.Error() == "Unhandled event":
-				continue
+	for i := range commands {
+		if commands[i].name == args[0] {
+			return &commands[i], args[1:]
+		}
+	}
+	return nil, args
+}
 
-			case err != nil:
-				log.Println("Error parsing event:", err, in.Data)
-				continue
+// newFlagSet returns the flag set for a command, with help describing its
+// arguments.
+func newFlagSet(name string) *flag.FlagSet {
 
-			default:
-				out <- *e
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
 
+	fs.Usage = func() {
+		for _, cmd := range commands {
+			if cmd.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: marathon-client %s [flags] %s\n\n%s.\n\nFlags:\n", name, cmd.args, cmd.help)
 			}
 		}
+		fs.PrintDefaults()
 	}
+	return fs
 }
 
-type Job map[string]interface{}
-
-func NewJob(data []byte) (j Job, err error) {
-	err = json.Unmarshal(data, &j)
-	if err != nil {
-		return
-	}
-	if _, ok := j["id"]; !ok {
-		err = errors.New("Missing ID")
-		return
-	}
-	if j["id"] == "" {
-		err = errors.New("ID is empty")
-		return
-	}
-	switch j["id"].(type) {
-	case string:
-		return
-	default:
-		err = errors.New("Invalid JSON")
-		return
-	}
+// connection holds the options shared by every command for connecting to
+// Marathon.
+type connection struct {
+	rawurl     string
+	user, pass string
+	debug      bool
+	timeout    time.Duration
+
+	retries       int
+	retryBackoff  time.Duration
+	retryStatuses string
+
+	token          string
+	tokenFile      string
+	serviceAccount string
+
+	caFile, certFile, keyFile string
+	tlsMinVersion             string
+	tlsServerName             string
+	insecure                  bool
 }
 
-func (j Job) IsGroup() bool {
-	if _, ok := j["groups"]; ok {
-		return true
-	}
-	if _, ok := j["apps"]; ok {
-		return true
-	}
-	return false
+func connectionFlags(fs *flag.FlagSet) *connection {
+	c := new(connection)
+	fs.StringVar(&c.rawurl, "m", "", "Marathon URL, or a comma separated list of the URLs of each instance")
+	fs.StringVar(&c.user, "u", "", "Username for basic auth")
+	fs.StringVar(&c.pass, "p", "", "Password for basic auth")
+	fs.StringVar(&c.token, "token", "", "DC/OS ACS token to authenticate with, instead of basic auth")
+	fs.StringVar(&c.tokenFile, "token-file", "", "File holding a DC/OS ACS token, read again if the token is rejected")
+	fs.StringVar(&c.serviceAccount, "service-account", "", "DC/OS service account secret file, with uid, private_key and optionally login_endpoint, to log in with")
+	fs.StringVar(&c.caFile, "ca-file", "", "PEM file of CA certificates, or a directory of them, to trust as well as the system's")
+	fs.StringVar(&c.certFile, "cert", "", "PEM client certificate to present, for mutual TLS")
+	fs.StringVar(&c.keyFile, "key", "", "PEM private key of the -cert client certificate")
+	fs.StringVar(&c.tlsMinVersion, "tls-min-version", "", "Lowest TLS version to use: 1.0, 1.1, 1.2 or 1.3")
+	fs.StringVar(&c.tlsServerName, "tls-server-name", "", "Server name to send with SNI and check the certificate against, instead of the host in -m")
+	fs.BoolVar(&c.insecure, "insecure-skip-verify", false, "Don't verify the Marathon TLS certificate, only for lab clusters")
+	fs.BoolVar(&c.debug, "d", false, "Debug output")
+	fs.DurationVar(&c.timeout, "timeout", 0, "Give up if the run takes longer than this, e.g. 10m (0 waits forever)")
+	fs.IntVar(&c.retries, "retries", marathon.DefaultRetryPolicy.MaxAttempts, "Most attempts at each request to Marathon, retrying network errors and -retry-statuses (1 to never retry)")
+	fs.DurationVar(&c.retryBackoff, "retry-backoff", marathon.DefaultRetryPolicy.Backoff, "Wait before the first retry, doubling for each one after")
+	fs.StringVar(&c.retryStatuses, "retry-statuses", joinInts(marathon.DefaultRetryPolicy.Statuses), "Comma separated HTTP status codes to retry")
+	return c
 }
 
-func (j Job) Id() string {
-	id := j["id"]
-	if id.(string)[0] == '/' {
-		return id.(string)
-	} else {
-		return "/" + id.(string)
+// joinInts formats a list of numbers as a comma separated flag value.
+func joinInts(list []int) string {
+	parts := make([]string, len(list))
+	for i, n := range list {
+		parts[i] = strconv.Itoa(n)
 	}
+	return strings.Join(parts, ",")
 }
 
-func (j Job) Data() ([]byte, error) {
-	return json.Marshal(&j)
-}
+// client connects to Marathon, exiting on any error.
+func (c *connection) client() *marathon.Client {
 
-func main() {
-	if rawurl == "" {
+	if c.rawurl == "" {
 		log.Fatal("Marathon URL (-m) is required")
 	}
 
-	if rawurl[0:4] != "http" {
-		// default to http
-		rawurl = "http://" + rawurl
-	}
-
-	if file == "" {
-		log.Fatal("Marathon job (-f) is required")
+	client, err := marathon.NewClient(c.rawurl)
+	if err != nil {
+		log.Fatal(err)
 	}
+	client.SetBasicAuth(c.user, c.pass)
+	client.Debug = c.debug
 
-	var data []byte
-	var err error
-
-	if file == "-" {
-		data, err = ioutil.ReadAll(os.Stdin)
-	} else {
-		data, err = ioutil.ReadFile(file)
-	}
+	// Before the authenticator, which logs in with the same transport
+	minVersion, err := marathon.ParseTLSVersion(c.tlsMinVersion)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	job, err := NewJob(data)
+	err = client.SetTLS(marathon.TLSOptions{
+		CA:         c.caFile,
+		CertFile:   c.certFile,
+		KeyFile:    c.keyFile,
+		MinVersion: minVersion,
+		ServerName: c.tlsServerName,
+		Insecure:   c.insecure,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	rawEvents := make(chan RawEvent, 64)
-	events := make(chan Event, 64)
+	client.Auth = c.authenticator(client)
 
-	// Exit cleanly
-	defer close(rawEvents)
+	client.Retry.MaxAttempts = c.retries
+	client.Retry.Backoff = c.retryBackoff
+	client.Retry.Statuses = nil
+	for _, s := range splitList(c.retryStatuses) {
+		status, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			log.Fatal("Invalid HTTP status code in -retry-statuses: ", s)
+		}
+		client.Retry.Statuses = append(client.Retry.Statuses, status)
+	}
 
-	err = EventListener(rawurl, rawEvents)
-	if err != nil {
-		log.Fatal(err)
+	return client
+}
+
+// authenticator returns the token authentication asked for, if any,
+// exiting if more than one kind is given.
+func (c *connection) authenticator(client *marathon.Client) marathon.Authenticator {
+
+	kinds := 0
+	for _, set := range []bool{c.user != "" || c.pass != "", c.token != "", c.tokenFile != "", c.serviceAccount != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		log.Fatal("Only one of basic auth (-u and -p), -token, -token-file or -service-account can be used")
 	}
 
-	// Run the event bus
-	go eventBus(rawEvents, events)
+	switch {
+
+	case c.token != "":
+		return marathon.StaticToken(c.token)
+
+	case c.tokenFile != "":
+		return &marathon.TokenFile{Path: c.tokenFile}
+
+	case c.serviceAccount != "":
+		secret, err := ioutil.ReadFile(c.serviceAccount)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sa, err := marathon.NewServiceAccount(secret)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// The same TLS settings, but not the redirect policy, which would
+		// add Marathon credentials the login doesn't need
+		sa.HTTPClient = &http.Client{Transport: client.HTTPClient.Transport}
+		return sa
 
-	// Start listening for events
-	err = EventListener(rawurl, rawEvents)
-	if err != nil {
-		log.Fatal(err)
 	}
+	return nil
+}
 
-	// Create the deployment job
-	id, err := DeployApplication(rawurl, job)
-	if err != nil {
-		log.Fatal(err)
+// signalContext returns a context cancelled on Ctrl-C, which is the root for
+// any further contexts.
+func (c *connection) signalContext() (context.Context, context.CancelFunc) {
+
+	root, stop := context.WithCancel(context.Background())
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		log.Println("Interrupted, stopping")
+		stop()
+	}()
+
+	return root, stop
+}
+
+// withTimeout applies the -timeout flag to a context.
+func (c *connection) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(ctx, c.timeout)
 	}
+	return context.WithCancel(ctx)
+}
 
-	dur, err := TrackDeployment(id, events)
-	if err != nil {
-		log.Println("Deployment failed")
-		log.Printf("%s: %6.2f %s\n", "Duration", dur.Seconds(), "seconds")
-		log.Println("Reason:", err)
-		os.Exit(1)
-	} else {
-		log.Println("Deployment succeeded")
-		log.Printf("%s: %6.2f %s\n", "Duration", dur.Seconds(), "seconds")
+// arguments checks the number of arguments a command was given.
+func arguments(fs *flag.FlagSet, n int) []string {
+	if fs.NArg() != n {
+		fs.Usage()
+		os.Exit(2)
 	}
+	return fs.Args()
 }
@@ -2,101 +2,63 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-var testJson = `
-{
-	"id": "/product/service/myApp",
-	"cmd": "env && sleep 300",
-	"args": ["/bin/sh", "-c", "env && sleep 300"]
-}
-`
-
-var testBadJson = `
-{
-	"cmd": "env && sleep 300",
-	"args": ["/bin/sh", "-c", "env && sleep 300"]
-}
-`
+func TestLookup(t *testing.T) {
 
-var testEmptyIDJson = `
-{
-	"id": "",
-	"cmd": "env && sleep 300",
-	"args": ["/bin/sh", "-c", "env && sleep 300"]
-}
-`
-
-var testGroupJson = `
-{
-	"id": "/product",
-	"apps": [ 
-		{
-			"id":"/product/service",
-			"cmd": "env && sleep 300"
+	for _, cmd := range commands {
+		found, args := lookup([]string{cmd.name, "-m", "http://marathon:8080", "my-app"})
+		if assert.NotNil(t, found, cmd.name) {
+			assert.Equal(t, cmd.name, found.name)
+			assert.Equal(t, []string{"-m", "http://marathon:8080", "my-app"}, args)
 		}
-	]
-}
-`
-
-func TestEventBus(t *testing.T) {
-
-	var raw RawEvent
-	raw.Name = "api_post_event"
-	raw.Data = []byte(re.ReplaceAllString(event_tests["api_post_event"], ""))
-
-	in := make(chan RawEvent)
-	out := make(chan Event)
+	}
 
-	go eventBus(in, out)
+	found, _ := lookup([]string{"bogus"})
+	assert.Nil(t, found)
 
-	select {
+	found, _ = lookup([]string{"help"})
+	assert.Nil(t, found)
+}
 
-	case parsed, ok := <-out:
-		if ok {
-			assert.Equal(t, "api_post_event", parsed.ApiPostEvent.EventType)
-			assert.Equal(t, "0:0:0:0:0:0:0:1", parsed.ApiPostEvent.ClientIp)
-			assert.Equal(t, "2014-03-01 23:29:30.158 +0000 UTC", parsed.ApiPostEvent.Timestamp.String())
+func TestLookupLegacy(t *testing.T) {
+
+	// Flags with no command, or nothing at all
+	for _, args := range [][]string{
+		{"-m", "http://marathon:8080", "-f", "job.json"},
+		{},
+	} {
+		found, rest := lookup(args)
+		if assert.NotNil(t, found) {
+			assert.Equal(t, legacy.name, found.name)
+			assert.Equal(t, args, rest)
 		}
-
-	default:
-		in <- raw
-		close(in)
-
 	}
-
 }
 
-func TestNewJob(t *testing.T) {
-
-	j, err := NewJob([]byte(testJson))
-	if err != nil {
-		t.Error(err)
-	}
-
-	if j.IsGroup() {
-		t.Error("Should not be a group")
-	}
-
-	j, err = NewJob([]byte(testBadJson))
-	if err == nil {
-		t.Error("No error with missing ID")
-	}
-
-	j, err = NewJob([]byte(testEmptyIDJson))
-	if err == nil {
-		t.Error("No error with empty ID")
-	}
-
-	j, err = NewJob([]byte(testGroupJson))
-	if err != nil {
-		t.Error(err)
-	}
-
-	if !j.IsGroup() {
-		t.Error("Should be a group", j)
-	}
-
+func TestLegacyFlags(t *testing.T) {
+
+	conn, trk, opts := legacyFlags("marathon-client", []string{
+		"-m", "http://marathon:8080",
+		"-f", "job.json",
+		"-delete",
+		"-force",
+		"-track", "poll",
+		"-timeout", "10m",
+	})
+
+	assert.Equal(t, "http://marathon:8080", conn.rawurl)
+	assert.Equal(t, 10*time.Minute, conn.timeout)
+	assert.True(t, trk.force)
+	assert.Equal(t, "poll", trk.mode)
+	assert.Equal(t, "job.json", opts.file)
+	assert.True(t, opts.delete)
+
+	// Deploys by default
+	_, _, opts = legacyFlags("marathon-client", []string{"-f", "job.json", "-rollback"})
+	assert.False(t, opts.delete)
+	assert.True(t, opts.rollback)
 }
@@ -0,0 +1,291 @@
+package marathon
+
+import (
+	"encoding/json"
+)
+
+//
+// Marathon application definitions
+//
+
+// App is a Marathon application definition.  Optional fields are pointers
+// so that unset fields are left out, and any fields without a matching
+// struct field are kept in Extra.
+type App struct {
+	Id                    string                `json:"id"`
+	Cmd                   *string               `json:"cmd,omitempty"`
+	Args                  *[]string             `json:"args,omitempty"`
+	User                  *string               `json:"user,omitempty"`
+	Env                   *map[string]EnvVar    `json:"env,omitempty"`
+	Instances             *int                  `json:"instances,omitempty"`
+	Cpus                  *float64              `json:"cpus,omitempty"`
+	Mem                   *float64              `json:"mem,omitempty"`
+	Disk                  *float64              `json:"disk,omitempty"`
+	Gpus                  *int                  `json:"gpus,omitempty"`
+	Constraints           *[][]string           `json:"constraints,omitempty"`
+	AcceptedResourceRoles *[]string             `json:"acceptedResourceRoles,omitempty"`
+	Fetch                 *[]Fetch              `json:"fetch,omitempty"`
+	BackoffSeconds        *float64              `json:"backoffSeconds,omitempty"`
+	BackoffFactor         *float64              `json:"backoffFactor,omitempty"`
+	MaxLaunchDelaySeconds *float64              `json:"maxLaunchDelaySeconds,omitempty"`
+	Container             *Container            `json:"container,omitempty"`
+	PortDefinitions       *[]PortDefinition     `json:"portDefinitions,omitempty"`
+	RequirePorts          *bool                 `json:"requirePorts,omitempty"`
+	HealthChecks          *[]HealthCheck        `json:"healthChecks,omitempty"`
+	ReadinessChecks       *[]ReadinessCheck     `json:"readinessChecks,omitempty"`
+	Dependencies          *[]string             `json:"dependencies,omitempty"`
+	UpgradeStrategy       *UpgradeStrategy      `json:"upgradeStrategy,omitempty"`
+	Labels                *map[string]string    `json:"labels,omitempty"`
+	Secrets               *map[string]SecretDef `json:"secrets,omitempty"`
+	Version               string                `json:"version,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (a App) MarshalJSON() ([]byte, error) {
+	type plain App
+	return marshalExtra(plain(a), a.Extra)
+}
+
+func (a *App) UnmarshalJSON(data []byte) error {
+	type plain App
+	return unmarshalExtra(data, (*plain)(a), &a.Extra)
+}
+
+// EnvVar is an environment variable, either a plain value or a reference
+// to one of the app's secrets.
+type EnvVar struct {
+	Value  string
+	Secret string
+}
+
+func (e EnvVar) MarshalJSON() ([]byte, error) {
+	if e.Secret != "" {
+		return json.Marshal(map[string]string{"secret": e.Secret})
+	}
+	return json.Marshal(e.Value)
+}
+
+func (e *EnvVar) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		var ref struct {
+			Secret string
+		}
+		err := json.Unmarshal(data, &ref)
+		e.Secret = ref.Secret
+		return err
+	}
+	return json.Unmarshal(data, &e.Value)
+}
+
+// SecretDef is the source of a secret made available to an app.
+type SecretDef struct {
+	Source string `json:"source"`
+
+	Extra Extra `json:"-"`
+}
+
+func (s SecretDef) MarshalJSON() ([]byte, error) {
+	type plain SecretDef
+	return marshalExtra(plain(s), s.Extra)
+}
+
+func (s *SecretDef) UnmarshalJSON(data []byte) error {
+	type plain SecretDef
+	return unmarshalExtra(data, (*plain)(s), &s.Extra)
+}
+
+// Fetch is a URI downloaded into the sandbox before a task starts.
+type Fetch struct {
+	Uri        string `json:"uri"`
+	Executable *bool  `json:"executable,omitempty"`
+	Extract    *bool  `json:"extract,omitempty"`
+	Cache      *bool  `json:"cache,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (f Fetch) MarshalJSON() ([]byte, error) {
+	type plain Fetch
+	return marshalExtra(plain(f), f.Extra)
+}
+
+func (f *Fetch) UnmarshalJSON(data []byte) error {
+	type plain Fetch
+	return unmarshalExtra(data, (*plain)(f), &f.Extra)
+}
+
+// Container describes the container an app runs in.
+type Container struct {
+	Type         string           `json:"type,omitempty"`
+	Docker       *DockerContainer `json:"docker,omitempty"`
+	Volumes      *[]Volume        `json:"volumes,omitempty"`
+	PortMappings *[]PortMapping   `json:"portMappings,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (c Container) MarshalJSON() ([]byte, error) {
+	type plain Container
+	return marshalExtra(plain(c), c.Extra)
+}
+
+func (c *Container) UnmarshalJSON(data []byte) error {
+	type plain Container
+	return unmarshalExtra(data, (*plain)(c), &c.Extra)
+}
+
+type DockerContainer struct {
+	Image          string         `json:"image"`
+	Network        string         `json:"network,omitempty"`
+	PortMappings   *[]PortMapping `json:"portMappings,omitempty"`
+	Privileged     *bool          `json:"privileged,omitempty"`
+	Parameters     *[]Parameter   `json:"parameters,omitempty"`
+	ForcePullImage *bool          `json:"forcePullImage,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (d DockerContainer) MarshalJSON() ([]byte, error) {
+	type plain DockerContainer
+	return marshalExtra(plain(d), d.Extra)
+}
+
+func (d *DockerContainer) UnmarshalJSON(data []byte) error {
+	type plain DockerContainer
+	return unmarshalExtra(data, (*plain)(d), &d.Extra)
+}
+
+// Parameter is an extra docker run option.
+type Parameter struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type Volume struct {
+	ContainerPath string `json:"containerPath,omitempty"`
+	HostPath      string `json:"hostPath,omitempty"`
+	Mode          string `json:"mode,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (v Volume) MarshalJSON() ([]byte, error) {
+	type plain Volume
+	return marshalExtra(plain(v), v.Extra)
+}
+
+func (v *Volume) UnmarshalJSON(data []byte) error {
+	type plain Volume
+	return unmarshalExtra(data, (*plain)(v), &v.Extra)
+}
+
+type PortMapping struct {
+	ContainerPort *int               `json:"containerPort,omitempty"`
+	HostPort      *int               `json:"hostPort,omitempty"`
+	ServicePort   *int               `json:"servicePort,omitempty"`
+	Protocol      string             `json:"protocol,omitempty"`
+	Name          string             `json:"name,omitempty"`
+	Labels        *map[string]string `json:"labels,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (p PortMapping) MarshalJSON() ([]byte, error) {
+	type plain PortMapping
+	return marshalExtra(plain(p), p.Extra)
+}
+
+func (p *PortMapping) UnmarshalJSON(data []byte) error {
+	type plain PortMapping
+	return unmarshalExtra(data, (*plain)(p), &p.Extra)
+}
+
+type PortDefinition struct {
+	Port     *int               `json:"port,omitempty"`
+	Protocol string             `json:"protocol,omitempty"`
+	Name     string             `json:"name,omitempty"`
+	Labels   *map[string]string `json:"labels,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (p PortDefinition) MarshalJSON() ([]byte, error) {
+	type plain PortDefinition
+	return marshalExtra(plain(p), p.Extra)
+}
+
+func (p *PortDefinition) UnmarshalJSON(data []byte) error {
+	type plain PortDefinition
+	return unmarshalExtra(data, (*plain)(p), &p.Extra)
+}
+
+type HealthCheck struct {
+	Protocol               string   `json:"protocol,omitempty"`
+	Path                   string   `json:"path,omitempty"`
+	PortIndex              *int     `json:"portIndex,omitempty"`
+	Port                   *int     `json:"port,omitempty"`
+	Command                *Command `json:"command,omitempty"`
+	GracePeriodSeconds     *int     `json:"gracePeriodSeconds,omitempty"`
+	IntervalSeconds        *int     `json:"intervalSeconds,omitempty"`
+	TimeoutSeconds         *int     `json:"timeoutSeconds,omitempty"`
+	MaxConsecutiveFailures *int     `json:"maxConsecutiveFailures,omitempty"`
+	IgnoreHttp1xx          *bool    `json:"ignoreHttp1xx,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (h HealthCheck) MarshalJSON() ([]byte, error) {
+	type plain HealthCheck
+	return marshalExtra(plain(h), h.Extra)
+}
+
+func (h *HealthCheck) UnmarshalJSON(data []byte) error {
+	type plain HealthCheck
+	return unmarshalExtra(data, (*plain)(h), &h.Extra)
+}
+
+// Command is a shell command run by a COMMAND health check.
+type Command struct {
+	Value string `json:"value"`
+}
+
+type ReadinessCheck struct {
+	Name                    string `json:"name,omitempty"`
+	Protocol                string `json:"protocol,omitempty"`
+	Path                    string `json:"path,omitempty"`
+	PortName                string `json:"portName,omitempty"`
+	IntervalSeconds         *int   `json:"intervalSeconds,omitempty"`
+	TimeoutSeconds          *int   `json:"timeoutSeconds,omitempty"`
+	HttpStatusCodesForReady *[]int `json:"httpStatusCodesForReady,omitempty"`
+	PreserveLastResponse    *bool  `json:"preserveLastResponse,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (r ReadinessCheck) MarshalJSON() ([]byte, error) {
+	type plain ReadinessCheck
+	return marshalExtra(plain(r), r.Extra)
+}
+
+func (r *ReadinessCheck) UnmarshalJSON(data []byte) error {
+	type plain ReadinessCheck
+	return unmarshalExtra(data, (*plain)(r), &r.Extra)
+}
+
+type UpgradeStrategy struct {
+	MinimumHealthCapacity *float64 `json:"minimumHealthCapacity,omitempty"`
+	MaximumOverCapacity   *float64 `json:"maximumOverCapacity,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (u UpgradeStrategy) MarshalJSON() ([]byte, error) {
+	type plain UpgradeStrategy
+	return marshalExtra(plain(u), u.Extra)
+}
+
+func (u *UpgradeStrategy) UnmarshalJSON(data []byte) error {
+	type plain UpgradeStrategy
+	return unmarshalExtra(data, (*plain)(u), &u.Extra)
+}
@@ -0,0 +1,110 @@
+package marathon
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//
+// Tests for Marathon application definitions
+//
+
+var testFullApp = `{
+	"id": "/product/service/myApp",
+	"cmd": "env && sleep 300",
+	"args": [],
+	"instances": 3,
+	"cpus": 0.25,
+	"mem": 128,
+	"constraints": [["hostname", "UNIQUE"]],
+	"env": {
+		"PLAIN": "value",
+		"PASSWORD": {"secret": "pw"}
+	},
+	"secrets": {
+		"pw": {"source": "/product/password"}
+	},
+	"labels": {"team": "platform"},
+	"container": {
+		"type": "DOCKER",
+		"docker": {
+			"image": "nginx:1.13",
+			"network": "BRIDGE",
+			"portMappings": [{"containerPort": 80, "hostPort": 0, "protocol": "tcp", "futureField": true}],
+			"forcePullImage": false
+		},
+		"volumes": [{"containerPath": "/data", "hostPath": "/srv/data", "mode": "RW"}]
+	},
+	"portDefinitions": [{"port": 0, "protocol": "tcp", "name": "http"}],
+	"healthChecks": [{
+		"protocol": "MESOS_HTTP",
+		"path": "/health",
+		"portIndex": 0,
+		"gracePeriodSeconds": 30,
+		"maxConsecutiveFailures": 3,
+		"delaySeconds": 15
+	}],
+	"readinessChecks": [{
+		"name": "ready",
+		"protocol": "HTTP",
+		"path": "/ready",
+		"portName": "http",
+		"httpStatusCodesForReady": [200]
+	}],
+	"upgradeStrategy": {"minimumHealthCapacity": 1, "maximumOverCapacity": 0},
+	"unreachableStrategy": {"inactiveAfterSeconds": 300, "expungeAfterSeconds": 600},
+	"killSelection": "YOUNGEST_FIRST"
+}`
+
+func TestAppRoundTrip(t *testing.T) {
+
+	var app App
+	err := json.Unmarshal([]byte(testFullApp), &app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 3, *app.Instances)
+	assert.Equal(t, "nginx:1.13", app.Container.Docker.Image)
+	assert.Equal(t, EnvVar{Value: "value"}, (*app.Env)["PLAIN"])
+	assert.Equal(t, EnvVar{Secret: "pw"}, (*app.Env)["PASSWORD"])
+	assert.Equal(t, "/product/password", (*app.Secrets)["pw"].Source)
+	assert.Equal(t, 0.0, *app.UpgradeStrategy.MaximumOverCapacity)
+	assert.Contains(t, app.Extra, "unreachableStrategy")
+	assert.Contains(t, (*app.HealthChecks)[0].Extra, "delaySeconds")
+
+	out, err := json.Marshal(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, testFullApp, string(out))
+}
+
+func TestAppModify(t *testing.T) {
+
+	var app App
+	err := json.Unmarshal([]byte(testFullApp), &app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instances := 5
+	app.Instances = &instances
+	app.Container.Docker.Image = "nginx:1.15"
+
+	out, err := json.Marshal(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var check map[string]interface{}
+	err = json.Unmarshal(out, &check)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 5.0, check["instances"])
+	assert.Equal(t, "YOUNGEST_FIRST", check["killSelection"])
+}
@@ -0,0 +1,324 @@
+package marathon
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Authenticating with tokens
+//
+
+// Authenticator adds credentials to requests to Marathon, for when basic
+// auth isn't used.
+type Authenticator interface {
+	// Authorize adds credentials to a request.
+	Authorize(ctx context.Context, req *http.Request) error
+
+	// Refresh is called when a request is rejected with a 401, and
+	// reports whether there are new credentials to retry it with.
+	Refresh(ctx context.Context, rejected *http.Request) (bool, error)
+}
+
+// setToken sets the header DC/OS expects a token in.
+func setToken(req *http.Request, token string) {
+	req.Header.Set("Authorization", "token="+token)
+}
+
+// StaticToken authenticates with a fixed token, such as an ACS token from
+// `dcos config show core.dcos_acs_token`.
+type StaticToken string
+
+func (t StaticToken) Authorize(ctx context.Context, req *http.Request) error {
+	setToken(req, string(t))
+	return nil
+}
+
+func (t StaticToken) Refresh(ctx context.Context, rejected *http.Request) (bool, error) {
+	return false, nil
+}
+
+// TokenFile authenticates with a token read from a file, which is read
+// again if the token is rejected, so it can be rotated by something else.
+type TokenFile struct {
+	Path string
+
+	mu    sync.Mutex
+	token string
+}
+
+func (f *TokenFile) Authorize(ctx context.Context, req *http.Request) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.token == "" {
+		token, err := f.read()
+		if err != nil {
+			return err
+		}
+		f.token = token
+	}
+
+	setToken(req, f.token)
+	return nil
+}
+
+func (f *TokenFile) Refresh(ctx context.Context, rejected *http.Request) (bool, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Already read again for another request
+	if rejected.Header.Get("Authorization") != "token="+f.token {
+		return true, nil
+	}
+
+	token, err := f.read()
+	if err != nil {
+		return false, err
+	}
+
+	changed := token != f.token
+	f.token = token
+	return changed, nil
+}
+
+func (f *TokenFile) read() (string, error) {
+
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("Token file %s is empty", f.Path)
+	}
+	return token, nil
+}
+
+// loginPath is where DC/OS service accounts log in
+const loginPath = "/acs/api/v1/auth/login"
+
+// ServiceAccount authenticates as a DC/OS service account, logging in with
+// a token signed by its private key, and logging in again when the token
+// it is given expires.
+type ServiceAccount struct {
+	UID        string
+	PrivateKey *rsa.PrivateKey
+
+	// LoginURL is the DC/OS login endpoint, e.g.
+	// https://dcos.example.com/acs/api/v1/auth/login.  If empty, the one
+	// on the same host as the Marathon instance in use is used.
+	LoginURL string
+
+	// HTTPClient is used to log in, http.DefaultClient if nil.  It
+	// shouldn't be the Marathon client's, whose redirect policy adds the
+	// Marathon credentials: logging in needs none, so the default policy
+	// is enough.
+	HTTPClient *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+// loginExpiry is how long the signed login token is valid for.  It is
+// only used once, to get the token used with Marathon.
+const loginExpiry = 5 * time.Minute
+
+// NewServiceAccount reads a service account secret in the format used by
+// DC/OS, with uid, private_key and login_endpoint fields.  If the secret
+// has no login endpoint, the service account logs in on the same host as
+// the Marathon instance each request is sent to.
+func NewServiceAccount(secret []byte) (*ServiceAccount, error) {
+
+	var s struct {
+		UID           string
+		PrivateKey    string `json:"private_key"`
+		LoginEndpoint string `json:"login_endpoint"`
+	}
+
+	err := json.Unmarshal(secret, &s)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid service account secret: %v", err)
+	}
+	if s.UID == "" || s.PrivateKey == "" {
+		return nil, errors.New("Service account secret needs a uid and private_key")
+	}
+
+	key, err := parsePrivateKey([]byte(s.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	sa := &ServiceAccount{
+		UID:        s.UID,
+		PrivateKey: key,
+		LoginURL:   s.LoginEndpoint,
+	}
+
+	return sa, nil
+}
+
+// parsePrivateKey reads an RSA private key in PKCS #1 or PKCS #8 PEM form.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("Service account private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid service account private key: %v", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("Service account private key is not an RSA key")
+	}
+	return key, nil
+}
+
+func (sa *ServiceAccount) Authorize(ctx context.Context, req *http.Request) error {
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	if sa.token == "" {
+		err := sa.login(ctx, req.URL)
+		if err != nil {
+			return err
+		}
+	}
+
+	setToken(req, sa.token)
+	return nil
+}
+
+func (sa *ServiceAccount) Refresh(ctx context.Context, rejected *http.Request) (bool, error) {
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	// Another request rejected at the same time has already logged in
+	if rejected.Header.Get("Authorization") != "token="+sa.token {
+		return true, nil
+	}
+
+	err := sa.login(ctx, rejected.URL)
+	return err == nil, err
+}
+
+// login exchanges a login token signed by the private key for a token to
+// use with Marathon, the Marathon instance at marathon.
+func (sa *ServiceAccount) login(ctx context.Context, marathon *url.URL) error {
+
+	loginURL := sa.LoginURL
+	if loginURL == "" {
+		if marathon == nil {
+			return errors.New("Service account login URL is not set")
+		}
+		u := url.URL{Scheme: marathon.Scheme, Host: marathon.Host, Path: loginPath}
+		loginURL = u.String()
+	}
+
+	signed, err := signRS256(sa.PrivateKey, map[string]interface{}{
+		"uid": sa.UID,
+		"exp": time.Now().Add(loginExpiry).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(map[string]string{"uid": sa.UID, "token": signed})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", loginURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := sa.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Service account login failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Service account login failed. HTTP status: %s, message: %s", resp.Status, string(body))
+	}
+
+	var login struct {
+		Token string
+	}
+	err = json.Unmarshal(body, &login)
+	if err != nil {
+		return err
+	}
+	if login.Token == "" {
+		return errors.New("Service account login returned no token")
+	}
+
+	sa.token = login.Token
+	return nil
+}
+
+// signRS256 makes a JWT with the given claims, signed with RS256.
+func signRS256(key *rsa.PrivateKey, claims map[string]interface{}) (string, error) {
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
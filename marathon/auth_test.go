@@ -0,0 +1,274 @@
+package marathon
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testKey *rsa.PrivateKey
+
+func init() {
+	var err error
+	testKey, err = rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// verifyJWT checks a token was signed by testKey, and returns its claims.
+func verifyJWT(t *testing.T, token string) map[string]interface{} {
+
+	parts := strings.Split(token, ".")
+	if !assert.Len(t, parts, 3) {
+		return nil
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&testKey.PublicKey, crypto.SHA256, hash[:], sig))
+
+	var header map[string]string
+	data, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	assert.NoError(t, json.Unmarshal(data, &header))
+	assert.Equal(t, "RS256", header["alg"])
+
+	var claims map[string]interface{}
+	data, _ = base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, json.Unmarshal(data, &claims))
+	return claims
+}
+
+// tokenServer accepts requests with the token in valid.
+func tokenServer(valid *atomic.Value) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token="+valid.Load().(string) {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[]`)
+	}))
+}
+
+func TestStaticToken(t *testing.T) {
+
+	var valid atomic.Value
+	valid.Store("abc")
+
+	ts := tokenServer(&valid)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+	c.Auth = StaticToken("abc")
+
+	_, err := c.Deployments(context.Background())
+	assert.NoError(t, err)
+
+	c.Auth = StaticToken("wrong")
+	_, err = c.Deployments(context.Background())
+	assert.Error(t, err)
+}
+
+func TestTokenFile(t *testing.T) {
+
+	var valid atomic.Value
+	valid.Store("first")
+
+	ts := tokenServer(&valid)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("first\n"), 0600))
+
+	c := testClient(ts.URL)
+	c.Auth = &TokenFile{Path: path}
+	ctx := context.Background()
+
+	_, err = c.Deployments(ctx)
+	assert.NoError(t, err)
+
+	// Rotated, so read again when the old one is rejected
+	valid.Store("second")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("second\n"), 0600))
+
+	_, err = c.Deployments(ctx)
+	assert.NoError(t, err)
+
+	c.Auth = &TokenFile{Path: filepath.Join(dir, "missing")}
+	_, err = c.Deployments(ctx)
+	assert.Error(t, err)
+}
+
+func TestServiceAccount(t *testing.T) {
+
+	var valid atomic.Value
+	valid.Store("")
+
+	var logins int32
+
+	ts := tokenServer(&valid)
+	defer ts.Close()
+
+	login := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var body struct {
+			UID   string
+			Token string
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "deployer", body.UID)
+
+		claims := verifyJWT(t, body.Token)
+		assert.Equal(t, "deployer", claims["uid"])
+		assert.InDelta(t, time.Now().Add(loginExpiry).Unix(), claims["exp"], 5)
+
+		n := atomic.AddInt32(&logins, 1)
+		token := fmt.Sprintf("token-%d", n)
+		valid.Store(token)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"token": "%s"}`, token)
+	}))
+	defer login.Close()
+
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testKey)})
+	secret, _ := json.Marshal(map[string]string{
+		"scheme":         "RS256",
+		"uid":            "deployer",
+		"private_key":    string(key),
+		"login_endpoint": login.URL + loginPath,
+	})
+
+	sa, err := NewServiceAccount(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testClient(ts.URL)
+	c.Auth = sa
+	ctx := context.Background()
+
+	_, err = c.Deployments(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, logins)
+
+	// The token expires, so log in again
+	valid.Store("expired")
+
+	_, err = c.Deployments(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, logins)
+}
+
+func TestNewServiceAccount(t *testing.T) {
+
+	der, err := x509.MarshalPKCS8PrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	secret, _ := json.Marshal(map[string]string{"uid": "deployer", "private_key": string(key)})
+
+	sa, err := NewServiceAccount(secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "", sa.LoginURL)
+	assert.Equal(t, testKey.N, sa.PrivateKey.N)
+
+	_, err = NewServiceAccount([]byte(`{"uid": "deployer", "private_key": "nonsense"}`))
+	assert.Error(t, err)
+
+	_, err = NewServiceAccount([]byte(`{"uid": "deployer"}`))
+	assert.Error(t, err)
+}
+
+// loginInstance runs a Marathon instance on a DC/OS master, which hands
+// out tokens for the cluster.
+func loginInstance(valid *atomic.Value, logins *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.URL.Path == "/ping":
+			fmt.Fprint(w, "pong")
+
+		case r.URL.Path == leaderPath:
+			fmt.Fprintf(w, `{"leader": "%s"}`, r.Host)
+
+		case r.URL.Path == loginPath:
+			token := fmt.Sprintf("%s-%d", r.Host, atomic.AddInt32(logins, 1))
+			valid.Store(token)
+			fmt.Fprintf(w, `{"token": "%s"}`, token)
+
+		case r.Header.Get("Authorization") != "token="+valid.Load().(string):
+			http.Error(w, "Unauthorized", 401)
+
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+}
+
+func TestServiceAccountFailover(t *testing.T) {
+
+	var valid atomic.Value
+	valid.Store("")
+
+	var firstLogins, secondLogins int32
+
+	first := loginInstance(&valid, &firstLogins)
+	second := loginInstance(&valid, &secondLogins)
+	defer second.Close()
+
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testKey)})
+	secret, _ := json.Marshal(map[string]string{"uid": "deployer", "private_key": string(key)})
+
+	sa, err := NewServiceAccount(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testClient(first.URL + "," + second.URL)
+	c.Retry.Backoff = time.Millisecond
+	c.Auth = sa
+	ctx := context.Background()
+
+	_, err = c.Deployments(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, firstLogins)
+
+	// The first goes away as the token expires, so log in on the second
+	first.Close()
+	valid.Store("expired")
+
+	_, err = c.Deployments(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, secondLogins)
+}
@@ -0,0 +1,152 @@
+// Package marathon deploys applications and groups to a Marathon server
+// and tracks the resulting deployments over the event stream.
+package marathon // import "github.com/nutmegdevelopment/marathon-client/marathon"
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Client holds the connection details for a Marathon server.
+type Client struct {
+	// Base URL of the Marathon server, or the first of several
+	URL *url.URL
+
+	// URLs of every Marathon instance, when there are several.  Requests
+	// go to the leader, failing over to another instance if it goes away.
+	URLs []*url.URL
+	ha   endpoints
+
+	// Credentials for basic auth, only used when both are set
+	User string
+	Pass string
+
+	// Auth, if set, authenticates requests instead of basic auth
+	Auth Authenticator
+
+	HTTPClient *http.Client
+	Logger     *log.Logger
+
+	// Retry says which failed requests to Marathon are retried
+	Retry RetryPolicy
+
+	// Debug enables verbose logging
+	Debug bool
+
+	// MaxFailures fails a tracked deployment once this many failures,
+	// such as failed health checks, have been seen.  Zero means no limit.
+	MaxFailures int
+
+	// MaxTaskFailures fails a tracked deployment once any of its apps has
+	// had this many tasks fail, killed, lost or in error, catching apps
+	// that are crash looping.  Zero means no limit.
+	MaxTaskFailures int
+
+	// StallTimeout is how long a tracked deployment may go without
+	// progressing to its next step before the launch queue is checked to
+	// see why.  Zero disables the check.
+	StallTimeout time.Duration
+
+	// Events, if set, is the event stream DeployApplication and
+	// DeleteApplication watch to know when a deployment blocking them has
+	// finished.  Without it the blocking deployments are polled.  It should
+	// be the channel later passed to TrackDeployment.
+	Events <-chan Event
+
+	// MaxConflictWait is how long DeployApplication and DeleteApplication
+	// wait for deployments blocking them before giving up.  Zero means no
+	// limit.
+	MaxConflictWait time.Duration
+}
+
+// NewClient returns a client for the Marathon server at rawurl, or for
+// several instances given as a comma separated list of URLs.  The scheme
+// defaults to http if none is given.
+func NewClient(rawurl string) (c *Client, err error) {
+
+	urls, err := parseURLs(rawurl)
+	if err != nil {
+		return
+	}
+
+	c = &Client{
+		URL:    urls[0],
+		URLs:   urls,
+		Logger: log.New(os.Stderr, "", log.LstdFlags),
+		Retry:  DefaultRetryPolicy,
+	}
+	c.HTTPClient = &http.Client{CheckRedirect: c.checkRedirect}
+	return
+}
+
+// SetBasicAuth sets the credentials sent with every request.
+func (c *Client) SetBasicAuth(user, pass string) {
+	c.User = user
+	c.Pass = pass
+}
+
+func (c *Client) authenticate() bool {
+	return c.User != "" && c.Pass != ""
+}
+
+func (c *Client) debugln(v ...interface{}) {
+	if c.Debug {
+		c.Logger.Println(v...)
+	}
+}
+
+// endpoint returns the absolute URL for an API path.
+func (c *Client) endpoint(path string) *url.URL {
+	u := *c.base()
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	return &u
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, u *url.URL, body io.Reader) (req *http.Request, err error) {
+	req, err = http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return
+	}
+
+	err = c.authorize(req)
+	return
+}
+
+// authorize adds the credentials to a request, replacing any it has.
+func (c *Client) authorize(req *http.Request) error {
+
+	req.Header.Del("Authorization")
+
+	switch {
+	case c.Auth != nil:
+		return c.Auth.Authorize(req.Context(), req)
+	case c.authenticate():
+		req.SetBasicAuth(c.User, c.Pass)
+	}
+	return nil
+}
+
+// refresh gets new credentials after a request was rejected, and reports
+// whether it is worth retrying.
+func (c *Client) refresh(rejected *http.Request) bool {
+
+	if c.Auth == nil {
+		return false
+	}
+
+	ok, err := c.Auth.Refresh(rejected.Context(), rejected)
+	if err != nil {
+		c.Logger.Println("Unable to refresh credentials:", err)
+		return false
+	}
+	if ok {
+		c.debugln("Credentials refreshed")
+	}
+	return ok
+}
@@ -0,0 +1,643 @@
+package marathon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//
+// Track deployment lifecycle
+//
+
+// DeploymentEvents are the event types used by TrackDeployment.
+var DeploymentEvents = []string{
+	"deployment_info",
+	"deployment_step_success",
+	"deployment_step_failure",
+	"deployment_success",
+	"deployment_failed",
+	"add_health_check_event",
+	"failed_health_check_event",
+	"health_status_changed_event",
+	"status_update_event",
+	"instance_changed_event",
+	"instance_health_changed_event",
+}
+
+type appFailures struct {
+	apps    []string
+	actions []string
+
+	// Why the deployment stalled, if it did
+	diagnosis string
+}
+
+func (a *appFailures) print() string {
+	str := "Failure reason(s):"
+	for i := range a.apps {
+		str = fmt.Sprintf("%s\nApplication: %s\nAction: %s\n",
+			str, a.apps[i], a.actions[i])
+	}
+	if a.diagnosis != "" {
+		str = fmt.Sprintf("%s\n%s\n", str, a.diagnosis)
+	}
+	return str
+}
+
+func (a *appFailures) any() bool {
+	return len(a.apps) > 0 || a.diagnosis != ""
+}
+
+// tooMany checks the failures against the client's limit.
+func (a *appFailures) tooMany(c *Client) bool {
+	return c.MaxFailures > 0 && len(a.apps) >= c.MaxFailures
+}
+
+func (a *appFailures) add(app, action string) {
+	apps := append(a.apps, app)
+	a.apps = apps
+
+	actions := append(a.actions, action)
+	a.actions = actions
+}
+
+// taskFailed checks if a task state is one a crash looping app ends up in.
+func taskFailed(state string) bool {
+	switch state {
+	case "TASK_FAILED", "TASK_KILLED", "TASK_LOST", "TASK_ERROR":
+		return true
+	}
+	return false
+}
+
+// oldTask checks if a task belongs to a version of an app from before the
+// deployment, as those are killed as part of an upgrade.
+func oldTask(deployed Timestamp, version string) bool {
+	d := deployed.Time()
+	v := Timestamp(version).Time()
+	if d.IsZero() || v.IsZero() {
+		return false
+	}
+	return v.Before(d)
+}
+
+// stallTimer starts the countdown to checking on a stalled deployment,
+// returning nil if the check is disabled.
+func (c *Client) stallTimer() <-chan time.Time {
+	if c.StallTimeout <= 0 {
+		return nil
+	}
+	return time.After(c.StallTimeout)
+}
+
+// stalled records the diagnosis of a stalled deployment.
+func (c *Client) stalled(ctx context.Context, ids []string, failures *appFailures) {
+	failures.diagnosis = c.diagnoseStall(ctx, ids, c.StallTimeout)
+	c.Logger.Println(failures.diagnosis)
+}
+
+// TimeoutError is returned by TrackDeployment when the context deadline
+// passes before the deployment has finished.
+type TimeoutError struct {
+	Id       string
+	Elapsed  time.Duration
+	Progress []string
+	failures appFailures
+}
+
+func (e *TimeoutError) Error() string {
+	str := fmt.Sprintf("Timed out after %s waiting for deployment %s", e.Elapsed, e.Id)
+	if len(e.Progress) > 0 {
+		str = fmt.Sprintf("%s\nProgress:\n%s", str, strings.Join(e.Progress, "\n"))
+	}
+	if e.failures.any() {
+		str = fmt.Sprintf("%s\n%s", str, e.failures.print())
+	}
+	return str
+}
+
+// contextError returns a *TimeoutError if the context deadline has passed,
+// or the context error otherwise.
+func contextError(ctx context.Context, id string, elapsed time.Duration, progress []string, failures appFailures) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{
+			Id:       id,
+			Elapsed:  elapsed,
+			Progress: progress,
+			failures: failures,
+		}
+	}
+	return ctx.Err()
+}
+
+// lookupApp looks for an app or pod ID in a list of Actions
+func lookupApp(list []Action, appId string) bool {
+	for i := range list {
+		if list[i].Target() == appId {
+			return true
+		}
+	}
+	return false
+}
+
+// TrackDeployment follows the deployment with the given ID on the event
+// stream, and returns how long it took once it has finished.  An error is
+// returned if the deployment failed or the stream ended before it finished,
+// and a *TimeoutError if the context deadline passed first.  apps and pods
+// are those the deployment is expected to affect, which are checked if it
+// finishes while the stream is down before its plan has been seen.
+func (c *Client) TrackDeployment(ctx context.Context, id string, events <-chan Event, apps, pods []string) (duration time.Duration, err error) {
+
+	c.debugln("Tracking deployment ID:", id)
+
+	// Actions for this deployment
+	actions := make([]Action, 0)
+
+	var failures appFailures
+
+	// Failed tasks by app, to catch crash loops
+	taskFailures := make(map[string]int)
+
+	// Version the deployment is moving to
+	var version Timestamp
+
+	// Outcome and timing of each step
+	var steps stepProgress
+
+	// Human readable record of what has happened so far
+	var progress []string
+
+	var start, end time.Time
+
+	tracking := time.Now()
+
+	// Fires when no step has finished for a while
+	stall := c.stallTimer()
+
+	for {
+
+		var e Event
+		var ok bool
+
+		select {
+
+		case <-ctx.Done():
+			elapsed := time.Since(tracking)
+			return elapsed, contextError(ctx, id, elapsed, progress, failures)
+
+		case <-stall:
+			stall = nil
+
+			var ids []string
+			for i := range actions {
+				ids = append(ids, actions[i].Target())
+			}
+			c.stalled(ctx, ids, &failures)
+			continue
+
+		case e, ok = <-events:
+			if !ok {
+				return 0, errors.New("Failed to track deployment")
+			}
+
+		}
+
+		switch {
+
+		// Build list of actions, and set start time
+		case e.Name == "deployment_info" &&
+			e.DeploymentStatus.Plan.Id == id:
+
+			start = e.DeploymentStatus.Timestamp.Time()
+			actions = planActions(e.DeploymentStatus.Plan.Steps)
+			steps.plan(e.DeploymentStatus.Plan.Steps, start)
+			version = e.DeploymentStatus.Plan.Version
+			progress = append(progress, "Deployment started")
+			stall = c.stallTimer()
+
+		case (e.Name == "deployment_step_success" || e.Name == "deployment_step_failure") &&
+			e.DeploymentStatus.Plan.Id == id:
+
+			outcome := "Succeeded"
+			if e.Name == "deployment_step_failure" {
+				outcome = "Failed"
+			}
+
+			done, lines := steps.finish(e.DeploymentStatus.CurrentStep.Actions, outcome, e.DeploymentStatus.Timestamp.Time())
+			progress = append(progress, lines...)
+
+			if outcome == "Failed" {
+				for i := range done {
+					failures.add(done[i].Target(), done[i].Type)
+				}
+			} else {
+				stall = c.stallTimer()
+				failures.diagnosis = ""
+			}
+
+			for i := range lines {
+				c.Logger.Println(lines[i])
+			}
+
+		case e.Name == "add_health_check_event" &&
+			lookupApp(actions, e.AddHealthCheck.AppId):
+
+			if c.Debug {
+				c.Logger.Println("Healthcheck added for", e.AddHealthCheck.AppId)
+			}
+
+		case e.Name == "failed_health_check_event" &&
+			lookupApp(actions, e.FailedHealthCheck.AppId):
+
+			failures.add(e.FailedHealthCheck.AppId, "HealthCheck")
+
+			if c.Debug {
+				c.Logger.Println("Healthcheck failed for", e.FailedHealthCheck.AppId)
+			}
+
+		case e.Name == "health_status_changed_event" &&
+			lookupApp(actions, e.HealthStatusChanged.AppId):
+
+			if e.HealthStatusChanged.Alive {
+				steps.seen(e.HealthStatusChanged.AppId, e.HealthStatusChanged.Timestamp.Time())
+			}
+
+			if c.Debug {
+				c.Logger.Println(
+					"Healthcheck status for",
+					e.HealthStatusChanged.AppId,
+					"changed to",
+					e.HealthStatusChanged.Alive)
+
+			}
+
+		case e.Name == "status_update_event" &&
+			lookupApp(actions, e.MesosStatusUpdateEvent.AppId):
+
+			task := e.MesosStatusUpdateEvent
+
+			if taskFailed(task.TaskStatus) && !oldTask(version, task.Version) {
+
+				taskFailures[task.AppId]++
+				failures.add(task.AppId, fmt.Sprintf("Task %s: %s", task.TaskStatus, task.Message))
+
+				if c.Debug {
+					c.Logger.Println(task.AppId, "task", task.TaskStatus,
+						"on host", task.Host+":", task.Message)
+				}
+
+				if c.MaxTaskFailures > 0 && taskFailures[task.AppId] >= c.MaxTaskFailures {
+					err = fmt.Errorf("Deployment failed, %s is crash looping:\n%s", task.AppId, failures.print())
+					return time.Since(tracking), err
+				}
+
+			} else if taskFailed(task.TaskStatus) {
+				if c.Debug {
+					c.Logger.Println(task.AppId, "previous version task", task.TaskStatus,
+						"on host", task.Host+":", task.Message)
+				}
+
+			} else {
+				if task.TaskStatus == "TASK_RUNNING" {
+					steps.seen(task.AppId, task.Timestamp.Time())
+				}
+				if c.Debug {
+					c.Logger.Println(task.AppId,
+						"running on host", task.Host)
+				}
+			}
+
+		case e.Name == "instance_changed_event" &&
+			lookupApp(actions, e.InstanceChanged.RunSpecId):
+
+			switch e.InstanceChanged.Condition {
+			case "Error", "Failed", "Gone", "Dropped", "Unreachable":
+				failures.add(e.InstanceChanged.RunSpecId, "Instance "+e.InstanceChanged.Condition)
+			case "Running":
+				steps.seen(e.InstanceChanged.RunSpecId, e.InstanceChanged.Timestamp.Time())
+			}
+
+			if c.Debug {
+				c.Logger.Println(e.InstanceChanged.RunSpecId,
+					"instance", e.InstanceChanged.Condition,
+					"on host", e.InstanceChanged.Host)
+			}
+
+		case e.Name == "instance_health_changed_event" &&
+			lookupApp(actions, e.InstanceHealthChanged.RunSpecId):
+
+			healthy := e.InstanceHealthChanged.Healthy
+			if healthy != nil && !*healthy {
+				failures.add(e.InstanceHealthChanged.RunSpecId, "HealthCheck")
+			}
+			if healthy != nil && *healthy {
+				steps.seen(e.InstanceHealthChanged.RunSpecId, e.InstanceHealthChanged.Timestamp.Time())
+			}
+
+			if c.Debug && healthy != nil {
+				c.Logger.Println(
+					"Healthcheck status for",
+					e.InstanceHealthChanged.RunSpecId,
+					"changed to",
+					*healthy)
+			}
+
+		// Events may have been lost, check the deployment is still running
+		case e.Name == ReconnectedEvent:
+
+			done, rerr := c.resync(ctx, id, actions, apps, pods)
+			if !done {
+				continue
+			}
+
+			end = time.Now()
+			if start.Year() == 1 {
+				start = tracking
+			}
+			if rerr != nil {
+				return end.Sub(start), fmt.Errorf("%s:\n%s", "Deployment failed", rerr)
+			}
+			return end.Sub(start), nil
+
+		case e.Name == "deployment_success" &&
+			e.DeploymentStatus.Id == id:
+
+			end = e.DeploymentStatus.Timestamp.Time()
+			if start.Year() == 1 {
+				start = end
+			}
+			return end.Sub(start), nil
+
+		case e.Name == "deployment_failed" &&
+			e.DeploymentStatus.Id == id:
+
+			end = e.DeploymentStatus.Timestamp.Time()
+			if start.Year() == 1 {
+				start = end
+			}
+			err = fmt.Errorf("%s:\n%s", "Deployment failed", failures.print())
+			return end.Sub(start), err
+
+		}
+
+		if failures.tooMany(c) {
+			err = fmt.Errorf("%s:\n%s", "Deployment failed, too many failures", failures.print())
+			return time.Since(tracking), err
+		}
+	}
+
+}
+
+// stepProgress records the outcome and timing of each step of a
+// deployment, and of each action within it.
+type stepProgress struct {
+	steps []DeploymentStep
+	done  []bool
+
+	// When the current step started
+	started time.Time
+
+	// When each app or pod was last seen coming up in the current step
+	ready map[string]time.Time
+
+	// Steps finished, for when the plan is unknown
+	count int
+}
+
+func (s *stepProgress) plan(steps []DeploymentStep, start time.Time) {
+	s.steps = steps
+	s.done = make([]bool, len(steps))
+	s.started = start
+	s.ready = make(map[string]time.Time)
+}
+
+// seen records an app or pod coming up, which is when its action is
+// taken to have finished.
+func (s *stepProgress) seen(id string, at time.Time) {
+	if s.ready == nil || at.Before(s.started) {
+		return
+	}
+	s.ready[id] = at
+}
+
+// index finds the step in the plan a finished step corresponds to, the
+// first not yet done with the same actions.
+func (s *stepProgress) index(current []StepAction) int {
+
+	for i := range s.steps {
+		if !s.done[i] && sameActions(s.steps[i].Actions, current) {
+			return i
+		}
+	}
+
+	// No match, assume steps finish in order
+	for i := range s.steps {
+		if !s.done[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// finish records a step finishing at the given time, and returns its
+// actions and the progress lines describing it.
+func (s *stepProgress) finish(current []StepAction, outcome string, at time.Time) (actions []StepAction, lines []string) {
+
+	s.count++
+
+	i := s.index(current)
+
+	actions = current
+	if len(actions) == 0 && i >= 0 {
+		for _, a := range s.steps[i].Actions {
+			actions = append(actions, StepAction{Type: a.Action, App: a.App, Pod: a.Pod})
+		}
+	}
+
+	list := make([]Action, len(actions))
+	for j, a := range actions {
+		list[j] = Action{Action: a.Type, App: a.App, Pod: a.Pod}
+	}
+
+	var summary string
+	if i >= 0 {
+		s.done[i] = true
+		summary = fmt.Sprintf("Step %d/%d: %s %s", i+1, len(s.steps), describeActions(list), outcome)
+	} else {
+		summary = fmt.Sprintf("Step %d: %s %s", s.count, describeActions(list), outcome)
+	}
+
+	started := !s.started.IsZero() && !at.IsZero()
+	if started {
+		summary += " in " + roundDuration(at.Sub(s.started))
+	}
+	lines = append(lines, summary)
+
+	// Time each action separately when there are several
+	for _, a := range actions {
+		if len(actions) < 2 {
+			break
+		}
+
+		line := fmt.Sprintf("  %s %s %s", a.Target(), a.Type, outcome)
+
+		if started {
+			// Actions not seen coming up took the whole step
+			end := at
+			if ready, ok := s.ready[a.Target()]; ok && outcome == "Succeeded" && ready.Before(at) {
+				end = ready
+			}
+			line += " in " + roundDuration(end.Sub(s.started))
+		}
+		lines = append(lines, line)
+	}
+
+	s.started = at
+	s.ready = make(map[string]time.Time)
+
+	return
+}
+
+// sameActions checks if a planned step and a finished step are made up of
+// the same actions.
+func sameActions(planned []Action, current []StepAction) bool {
+
+	if len(planned) != len(current) {
+		return false
+	}
+
+	want := make(map[string]int)
+	for _, a := range planned {
+		want[a.Action+" "+a.Target()]++
+	}
+	for _, a := range current {
+		want[a.Type+" "+a.Target()]--
+	}
+	for k := range want {
+		if want[k] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func roundDuration(d time.Duration) string {
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// appState is the response from /v2/apps/{id}
+type appState struct {
+	App AppStatus
+}
+
+// resync checks whether a deployment is still in progress after the event
+// stream has been interrupted.  If it has finished, done is set and err
+// reports any of its apps that did not come up.  The apps and pods given
+// are checked if none of the deployment's actions are known.
+func (c *Client) resync(ctx context.Context, id string, actions []Action, apps, pods []string) (done bool, err error) {
+
+	list, err := c.Deployments(ctx)
+	if err != nil {
+		c.Logger.Println("Unable to resync deployment state:", err)
+		return false, nil
+	}
+
+	for i := range list {
+		if list[i].Id == id {
+			c.debugln("Deployment", id, "still in progress")
+			return false, nil
+		}
+	}
+
+	c.Logger.Println("Deployment", id, "finished while the event stream was down")
+
+	if len(actions) > 0 {
+		apps, pods = nil, nil
+	}
+	for i := range actions {
+		if actions[i].Pod != "" {
+			pods = append(pods, actions[i].Pod)
+		} else {
+			apps = append(apps, actions[i].App)
+		}
+	}
+
+	return true, c.checkApps(ctx, apps, pods)
+}
+
+// checkApps looks up the current state of apps and pods, and returns an
+// error describing any that are not fully running and healthy.  Any that
+// no longer exist are assumed to have been deleted.
+func (c *Client) checkApps(ctx context.Context, apps, pods []string) error {
+
+	var problems []string
+	seen := make(map[string]bool)
+
+	for _, pod := range pods {
+
+		if seen[pod] {
+			continue
+		}
+		seen[pod] = true
+
+		var state PodStatus
+
+		status, err := c.getJSON(ctx, c.endpoint(podPath+pod+"::status"), &state)
+		switch {
+
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("Pod: %s\nUnable to check state: %s", pod, err))
+
+		// Deleted
+		case status == 404:
+
+		case status != 200:
+			problems = append(problems, fmt.Sprintf("Pod: %s\nUnable to check state, HTTP status code: %d", pod, status))
+
+		case state.Status != "STABLE":
+			problems = append(problems, fmt.Sprintf("Pod: %s\nStatus %s, %d instances", pod, state.Status, len(state.Instances)))
+
+		}
+	}
+
+	for _, app := range apps {
+
+		if seen[app] {
+			continue
+		}
+		seen[app] = true
+
+		var state appState
+
+		status, err := c.getJSON(ctx, c.endpoint(appPath+app), &state)
+		switch {
+
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("Application: %s\nUnable to check state: %s", app, err))
+
+		// Deleted
+		case status == 404:
+
+		case status != 200:
+			problems = append(problems, fmt.Sprintf("Application: %s\nUnable to check state, HTTP status code: %d", app, status))
+
+		case state.App.TasksRunning < state.App.Instances || state.App.TasksUnhealthy > 0:
+			problem := fmt.Sprintf("Application: %s\n%d/%d tasks running, %d unhealthy",
+				app, state.App.TasksRunning, state.App.Instances, state.App.TasksUnhealthy)
+
+			if f := state.App.LastTaskFailure; f != nil && f.Version == state.App.Version {
+				problem = fmt.Sprintf("%s\nLast task failure: %s %s on %s", problem, f.State, f.Message, f.Host)
+			}
+			problems = append(problems, problem)
+
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
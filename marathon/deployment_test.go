@@ -0,0 +1,355 @@
+package marathon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var deploymentId = "867ed450-f6a8-4d33-9b0e-e11c5513990b"
+
+var targetLogOutput = `Tracking deployment ID: 867ed450-f6a8-4d33-9b0e-e11c5513990b
+Healthcheck added for /my-app
+Step 1/1: ScaleApplication /my-app Succeeded in 0s
+/my-app running on host slave-1234.acme.org
+Healthcheck status for /my-app changed to true
+Tracking deployment ID: 867ed450-f6a8-4d33-9b0e-e11c5513990b
+Healthcheck added for /my-app
+Step 1/1: ScaleApplication /my-app Failed in 0s
+/my-app running on host slave-1234.acme.org
+Healthcheck failed for /my-app
+`
+
+func TestTrackDeployment(t *testing.T) {
+
+	// Test that we log the correct output
+
+	var w bytes.Buffer
+
+	c := testClient("http://localhost")
+	c.Debug = true
+	c.Logger = log.New(&w, "", 0)
+
+	ch := make(chan Event, 64)
+
+	// Test a successful deployment
+	go func() {
+
+		eventList := []string{
+			"api_post_event",
+			"group_change_success",
+			"deployment_info",
+			"add_health_check_event",
+			"deployment_step_success",
+			"status_update_event",
+			"health_status_changed_event",
+			"deployment_success"}
+
+		for i := range eventList {
+			e, err := runEvent(eventList[i])
+			if err != nil {
+				close(ch)
+				t.Error(err)
+				return
+			}
+
+			ch <- e
+		}
+
+	}()
+
+	_, err := c.TrackDeployment(context.Background(), deploymentId, ch, nil, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Test a failed deployment
+	go func() {
+
+		eventList := []string{
+			"api_post_event",
+			"group_change_success",
+			"deployment_info",
+			"add_health_check_event",
+			"deployment_step_failure",
+			"status_update_event",
+			"failed_health_check_event",
+			"deployment_failed"}
+
+		for i := range eventList {
+			e, err := runEvent(eventList[i])
+			if err != nil {
+				close(ch)
+				t.Error(err)
+				return
+			}
+
+			ch <- e
+		}
+
+	}()
+
+	_, err = c.TrackDeployment(context.Background(), deploymentId, ch, nil, nil)
+	if err == nil {
+		t.Error("No error on failed deployment")
+	}
+
+	output, err := ioutil.ReadAll(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, targetLogOutput, string(output))
+
+}
+
+func TestTrackDeploymentTimeout(t *testing.T) {
+
+	c := testClient("http://localhost")
+
+	ch := make(chan Event, 64)
+
+	for _, name := range []string{"deployment_info", "deployment_step_success"} {
+		e, err := runEvent(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ch <- e
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.TrackDeployment(ctx, deploymentId, ch, nil, nil)
+
+	terr, ok := err.(*TimeoutError)
+	if !ok {
+		t.Fatalf("Expected a TimeoutError, got %v", err)
+	}
+
+	assert.Equal(t, deploymentId, terr.Id)
+	assert.Equal(t, []string{"Deployment started", "Step 1/1: ScaleApplication /my-app Succeeded in 0s"}, terr.Progress)
+
+	// Cancelling is not a timeout
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	_, err = c.TrackDeployment(ctx, deploymentId, ch, nil, nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestTrackDeploymentResync(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+
+		case deploymentPath:
+			fmt.Fprint(w, "[]")
+
+		case appPath + "/my-app":
+			fmt.Fprint(w, `{"app": {"id": "/my-app", "instances": 2, "tasksRunning": 1, "tasksUnhealthy": 0}}`)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	ch := make(chan Event, 64)
+
+	e, err := runEvent("deployment_info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch <- e
+	ch <- Event{Name: ReconnectedEvent}
+
+	_, err = c.TrackDeployment(context.Background(), deploymentId, ch, nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "1/2 tasks running")
+	}
+
+	// Finished before the plan was seen, the job's apps are checked
+	ch <- Event{Name: ReconnectedEvent}
+
+	_, err = c.TrackDeployment(context.Background(), deploymentId, ch, []string{"/my-app"}, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "1/2 tasks running")
+	}
+}
+
+func TestTrackPodDeployment(t *testing.T) {
+
+	c := testClient("http://localhost")
+
+	ch := make(chan Event, 64)
+
+	var info Event
+	info.Name = "deployment_info"
+	info.DeploymentStatus.Plan.Id = deploymentId
+	info.DeploymentStatus.Plan.Steps = []DeploymentStep{{Actions: []Action{{Action: "StartPod", Pod: "/product/pod"}}}}
+	ch <- info
+
+	e, err := runEvent("instance_health_changed_event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch <- e
+
+	var failed Event
+	failed.Name = "deployment_failed"
+	failed.DeploymentStatus.Id = deploymentId
+	ch <- failed
+
+	_, err = c.TrackDeployment(context.Background(), deploymentId, ch, nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Application: /product/pod\nAction: HealthCheck")
+	}
+}
+
+func TestTrackDeploymentMaxFailures(t *testing.T) {
+
+	c := testClient("http://localhost")
+	c.MaxFailures = 2
+
+	ch := make(chan Event, 64)
+
+	for _, name := range []string{"deployment_info", "failed_health_check_event", "failed_health_check_event"} {
+		e, err := runEvent(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ch <- e
+	}
+
+	_, err := c.TrackDeployment(context.Background(), deploymentId, ch, nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "too many failures")
+	}
+}
+
+func TestTrackDeploymentCrashLoop(t *testing.T) {
+
+	c := testClient("http://localhost")
+	c.MaxTaskFailures = 2
+
+	ch := make(chan Event, 64)
+
+	e, err := runEvent("deployment_info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.DeploymentStatus.Plan.Version = "2014-04-04T06:26:23.051Z"
+	ch <- e
+
+	task := func(status, version, message string) {
+		e, err := runEvent("status_update_event")
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.MesosStatusUpdateEvent.TaskStatus = status
+		e.MesosStatusUpdateEvent.Version = version
+		e.MesosStatusUpdateEvent.Message = message
+		ch <- e
+	}
+
+	// Old tasks being replaced are not counted
+	task("TASK_KILLED", "2014-04-01T00:00:00.000Z", "")
+	task("TASK_RUNNING", "2014-04-04T06:26:23.051Z", "")
+	task("TASK_FAILED", "2014-04-04T06:26:23.051Z", "Command exited with status 1")
+	task("TASK_LOST", "2014-04-04T06:26:23.051Z", "Agent removed")
+
+	_, err = c.TrackDeployment(context.Background(), deploymentId, ch, nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "/my-app is crash looping")
+		assert.Contains(t, err.Error(), "Action: Task TASK_FAILED: Command exited with status 1")
+		assert.Contains(t, err.Error(), "Action: Task TASK_LOST: Agent removed")
+		assert.NotContains(t, err.Error(), "TASK_KILLED")
+	}
+}
+
+func TestTrackGroupDeployment(t *testing.T) {
+
+	var w bytes.Buffer
+
+	c := testClient("http://localhost")
+	c.Logger = log.New(&w, "", 0)
+
+	ch := make(chan Event, 64)
+
+	at := func(seconds int) Timestamp {
+		return Timestamp(fmt.Sprintf("2017-01-01T00:00:%02d.000Z", seconds))
+	}
+
+	var info Event
+	info.Name = "deployment_info"
+	info.DeploymentStatus.Plan.Id = deploymentId
+	info.DeploymentStatus.Timestamp = at(0)
+	info.DeploymentStatus.Plan.Steps = []DeploymentStep{
+		{Actions: []Action{{Action: "StartApplication", App: "/a"}, {Action: "StartApplication", App: "/b"}}},
+		{Actions: []Action{{Action: "ScaleApplication", App: "/a"}, {Action: "ScaleApplication", App: "/b"}}},
+	}
+	ch <- info
+
+	var running Event
+	running.Name = "status_update_event"
+	running.MesosStatusUpdateEvent.AppId = "/a"
+	running.MesosStatusUpdateEvent.TaskStatus = "TASK_RUNNING"
+	running.MesosStatusUpdateEvent.Timestamp = at(1)
+	ch <- running
+
+	// Steps are matched by their actions
+	var step Event
+	step.Name = "deployment_step_success"
+	step.DeploymentStatus.Plan.Id = deploymentId
+	step.DeploymentStatus.Timestamp = at(3)
+	step.DeploymentStatus.CurrentStep.Actions = []StepAction{{Type: "StartApplication", App: "/a"}, {Type: "StartApplication", App: "/b"}}
+	ch <- step
+
+	running.MesosStatusUpdateEvent.Timestamp = at(5)
+	ch <- running
+
+	step.Name = "deployment_step_failure"
+	step.DeploymentStatus.Timestamp = at(10)
+	step.DeploymentStatus.CurrentStep.Actions = nil
+	ch <- step
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.TrackDeployment(ctx, deploymentId, ch, nil, nil)
+
+	terr, ok := err.(*TimeoutError)
+	if !ok {
+		t.Fatalf("Expected a TimeoutError, got %v", err)
+	}
+
+	assert.Equal(t, []string{
+		"Deployment started",
+		"Step 1/2: StartApplication /a, /b Succeeded in 3s",
+		"  /a StartApplication Succeeded in 1s",
+		"  /b StartApplication Succeeded in 3s",
+		"Step 2/2: ScaleApplication /a, /b Failed in 7s",
+		"  /a ScaleApplication Failed in 7s",
+		"  /b ScaleApplication Failed in 7s",
+	}, terr.Progress)
+
+	assert.Contains(t, err.Error(), "Application: /a\nAction: ScaleApplication")
+	assert.Contains(t, err.Error(), "Application: /b\nAction: ScaleApplication")
+
+	// Steps are logged as they finish without -d, but not every event
+	assert.Contains(t, w.String(), "Step 1/2: StartApplication /a, /b Succeeded in 3s\n  /a StartApplication Succeeded in 1s\n")
+	assert.Contains(t, w.String(), "Step 2/2: ScaleApplication /a, /b Failed in 7s\n")
+	assert.NotContains(t, w.String(), "running on host")
+}
@@ -0,0 +1,600 @@
+package marathon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//
+// Compare job definitions with what is running
+//
+
+// Fields filled in by Marathon that never come from a job file.
+var serverFields = map[string]bool{
+	"version":               true,
+	"versionInfo":           true,
+	"tasks":                 true,
+	"deployments":           true,
+	"tasksStaged":           true,
+	"tasksRunning":          true,
+	"tasksHealthy":          true,
+	"tasksUnhealthy":        true,
+	"lastTaskFailure":       true,
+	"taskStats":             true,
+	"readinessCheckResults": true,
+}
+
+// Fields Marathon fills in or derives from others when they are left out
+// of a definition.  They are only compared when the job sets them.
+var derivedFields = map[string]bool{
+	"ports":               true,
+	"uris":                true,
+	"fetch":               true,
+	"storeUrls":           true,
+	"portDefinitions":     true,
+	"networks":            true,
+	"residency":           true,
+	"ipAddress":           true,
+	"unreachableStrategy": true,
+	"role":                true,
+	"executorResources":   true,
+	"scheduling":          true,
+}
+
+// Marathon defaults, by field path.  Array indexes are left out of the
+// path, and paths within a group are relative to the app or pod.
+var defaultValues = map[string][]interface{}{
+	"instances":             {1.0},
+	"cpus":                  {1.0},
+	"mem":                   {128.0},
+	"disk":                  {0.0},
+	"gpus":                  {0.0},
+	"backoffSeconds":        {1.0},
+	"backoffFactor":         {1.15},
+	"maxLaunchDelaySeconds": {3600.0, 300.0},
+	"killSelection":         {"YOUNGEST_FIRST"},
+	"acceptedResourceRoles": {[]interface{}{"*"}},
+
+	"upgradeStrategy.minimumHealthCapacity": {1.0},
+	"upgradeStrategy.maximumOverCapacity":   {1.0},
+
+	"container.type":                         {"DOCKER"},
+	"container.portMappings.protocol":        {"tcp"},
+	"container.docker.portMappings.protocol": {"tcp"},
+	"portDefinitions.protocol":               {"tcp"},
+
+	"healthChecks.protocol":               {"HTTP"},
+	"healthChecks.path":                   {"/"},
+	"healthChecks.portIndex":              {0.0},
+	"healthChecks.gracePeriodSeconds":     {300.0},
+	"healthChecks.intervalSeconds":        {60.0},
+	"healthChecks.timeoutSeconds":         {20.0},
+	"healthChecks.maxConsecutiveFailures": {3.0},
+	"healthChecks.delaySeconds":           {15.0},
+
+	"readinessChecks.protocol":                {"HTTP"},
+	"readinessChecks.path":                    {"/"},
+	"readinessChecks.portName":                {"http-api"},
+	"readinessChecks.intervalSeconds":         {30.0},
+	"readinessChecks.timeoutSeconds":          {10.0},
+	"readinessChecks.httpStatusCodesForReady": {[]interface{}{200.0}},
+
+	"scaling.kind":                  {"fixed"},
+	"scaling.instances":             {1.0},
+	"containers.resources.disk":     {0.0},
+	"containers.resources.gpus":     {0.0},
+	"containers.image.forcePull":    {false},
+	"containers.endpoints.protocol": {[]interface{}{"tcp"}},
+}
+
+// Port fields where 0 asks Marathon to pick a port.
+var dynamicPorts = map[string]bool{
+	"port":        true,
+	"hostPort":    true,
+	"servicePort": true,
+}
+
+// ChangeType says how a field differs.
+type ChangeType int
+
+const (
+	Added ChangeType = iota
+	Removed
+	Modified
+)
+
+// Change is a field that differs between the live and desired definition
+// of a job.
+type Change struct {
+	Type ChangeType
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+func (c Change) String() string {
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", c.Path, jsonString(c.New))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", c.Path, jsonString(c.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s => %s", c.Path, jsonString(c.Old), jsonString(c.New))
+	}
+}
+
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// LiveDefinition fetches the definition of a job as Marathon has it.  It
+// returns nil if the job does not exist.
+func (c *Client) LiveDefinition(ctx context.Context, job Job) (def map[string]interface{}, err error) {
+
+	var status int
+
+	switch {
+
+	case job.IsGroup():
+		status, err = c.getJSON(ctx, c.endpoint(groupPath+job.Id()), &def)
+
+	case job.IsPod():
+		status, err = c.getJSON(ctx, c.endpoint(podPath+job.Id()), &def)
+
+	default:
+		var resp struct {
+			App map[string]interface{}
+		}
+		status, err = c.getJSON(ctx, c.endpoint(appPath+job.Id()), &resp)
+		def = resp.App
+
+	}
+
+	switch {
+	case err != nil:
+	case status == 404:
+		def = nil
+	case status != 200:
+		err = fmt.Errorf("Unexpected response code. HTTP status code: %d", status)
+	}
+	return
+}
+
+// Diff compares a job with its live definition, and returns the changes
+// deploying it would make.  Fields set by Marathon and defaults left out
+// of the job are ignored.  If the job doesn't exist every field is added.
+func (c *Client) Diff(ctx context.Context, job Job) (changes []Change, err error) {
+
+	live, err := c.LiveDefinition(ctx, job)
+	if err != nil {
+		return
+	}
+
+	desired, err := jobDefinition(job)
+	if err != nil {
+		return
+	}
+
+	if live == nil {
+		live = make(map[string]interface{})
+	}
+
+	return diffDefinitions(job, live, desired), nil
+}
+
+// Unchanged checks whether deploying a job would change anything.  If the
+// job has been stamped with a hash that matches the live definition it is
+// unchanged, otherwise the definitions are compared as in Diff.
+func (c *Client) Unchanged(ctx context.Context, job Job) (unchanged bool, err error) {
+
+	live, err := c.LiveDefinition(ctx, job)
+	if err != nil || live == nil {
+		return
+	}
+
+	if hash := stampedHash(job); hash != "" && liveHashMatches(live, hash) {
+		c.debugln("Hash label matches live definition")
+		return true, nil
+	}
+
+	desired, err := jobDefinition(job)
+	if err != nil {
+		return
+	}
+
+	return len(diffDefinitions(job, live, desired)) == 0, nil
+}
+
+// stampedHash returns the hash label set on a job by StampHash.
+func stampedHash(job Job) string {
+
+	var labels *map[string]string
+
+	switch {
+	case job.App != nil:
+		labels = job.App.Labels
+	case job.Pod != nil:
+		labels = job.Pod.Labels
+	case job.Group != nil:
+		// Every app and pod in the group has the same hash
+		labels = firstLabels(job.Group)
+	}
+
+	if labels == nil {
+		return ""
+	}
+	return (*labels)[HashLabel]
+}
+
+// firstLabels returns the labels of the first app or pod in a group.
+func firstLabels(g *Group) *map[string]string {
+	if g.Apps != nil && len(*g.Apps) > 0 {
+		return (*g.Apps)[0].Labels
+	}
+	if g.Pods != nil && len(*g.Pods) > 0 {
+		return (*g.Pods)[0].Labels
+	}
+	if g.Groups != nil {
+		for i := range *g.Groups {
+			if labels := firstLabels(&(*g.Groups)[i]); labels != nil {
+				return labels
+			}
+		}
+	}
+	return nil
+}
+
+// liveHashMatches checks the hash label of a live definition.  For a group
+// every app and pod must have it.
+func liveHashMatches(def map[string]interface{}, hash string) bool {
+
+	var runs int
+
+	var check func(def map[string]interface{}, group bool) bool
+	check = func(def map[string]interface{}, group bool) bool {
+
+		if !group {
+			runs++
+			labels, _ := def["labels"].(map[string]interface{})
+			return labels[HashLabel] == hash
+		}
+
+		for _, k := range []string{"apps", "pods", "groups"} {
+			list, _ := def[k].([]interface{})
+			for _, v := range list {
+				child, ok := v.(map[string]interface{})
+				if !ok || !check(child, k == "groups") {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	_, apps := def["apps"]
+	_, pods := def["pods"]
+	_, groups := def["groups"]
+
+	return check(def, apps || pods || groups) && runs > 0
+}
+
+// jobDefinition returns the generic JSON form of a job.
+func jobDefinition(job Job) (def map[string]interface{}, err error) {
+	data, err := job.Data()
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &def)
+	return
+}
+
+// diffDefinitions normalises both definitions and compares them.
+func diffDefinitions(job Job, live, desired map[string]interface{}) (changes []Change) {
+
+	id := job.Id()
+	live = normalise(live, desired, "", id).(map[string]interface{})
+	desired = normalise(desired, desired, "", id).(map[string]interface{})
+
+	diffValues("", live, desired, &changes)
+	return
+}
+
+// normalise strips the fields of v that are set by Marathon, and any that
+// are left out of the job and have their default value.  ref is the part
+// of the job matching v, and id the ID of the enclosing app, pod or group.
+func normalise(v, ref interface{}, path, id string) interface{} {
+
+	switch v := v.(type) {
+
+	case map[string]interface{}:
+
+		refMap, _ := ref.(map[string]interface{})
+
+		out := make(map[string]interface{})
+
+		for k, val := range v {
+
+			if serverFields[k] {
+				continue
+			}
+
+			refVal, set := refMap[k]
+
+			if !set && (derivedFields[k] || dynamicPorts[k] || isDefault(join(path, k), val)) {
+				continue
+			}
+
+			var n interface{}
+
+			switch {
+
+			// Apps, pods and groups within a group are normalised on
+			// their own, with absolute IDs
+			case path == "" && (k == "apps" || k == "pods" || k == "groups"):
+				n = normaliseChildren(val, refVal, id)
+
+			case path == "" && k == "id":
+				n = val
+				if _, ok := val.(string); ok {
+					n = id
+				}
+
+			default:
+				n = normalise(val, refVal, join(path, k), id)
+
+			}
+
+			// Empty once defaults are removed
+			if !set && isEmpty(n) {
+				continue
+			}
+
+			out[k] = n
+		}
+
+		return out
+
+	case []interface{}:
+
+		refList, _ := ref.([]interface{})
+
+		out := make([]interface{}, len(v))
+		for i := range v {
+			var r interface{}
+			if m := matchElement(v[i], refList, i); m != nil {
+				r = m
+			}
+			out[i] = normalise(v[i], r, path, id)
+		}
+		return out
+
+	}
+
+	return v
+}
+
+// normaliseChildren normalises the apps, pods or groups of a group.
+func normaliseChildren(v, ref interface{}, parent string) interface{} {
+
+	list, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+
+	refList, _ := ref.([]interface{})
+
+	out := make([]interface{}, len(list))
+
+	for i := range list {
+
+		id := parent
+		if m, ok := list[i].(map[string]interface{}); ok {
+			if s, ok := m["id"].(string); ok {
+				id = absoluteId(s, parent)
+			}
+		}
+
+		out[i] = normalise(list[i], matchElement(list[i], refList, i), "", id)
+	}
+
+	return out
+}
+
+// matchElement finds the element of ref matching v, by ID if it has one,
+// and by position otherwise.
+func matchElement(v interface{}, ref []interface{}, i int) interface{} {
+
+	if id, ok := elementId(v); ok {
+		for _, r := range ref {
+			if rid, ok := elementId(r); ok && strings.TrimLeft(rid, "/") == strings.TrimLeft(id, "/") {
+				return r
+			}
+		}
+		for _, r := range ref {
+			if rid, ok := elementId(r); ok && strings.HasSuffix(id, "/"+strings.TrimLeft(rid, "/")) {
+				return r
+			}
+		}
+		return nil
+	}
+
+	if i < len(ref) {
+		return ref[i]
+	}
+	return nil
+}
+
+func elementId(v interface{}) (id string, ok bool) {
+	m, isMap := v.(map[string]interface{})
+	if !isMap {
+		return
+	}
+	id, ok = m["id"].(string)
+	return
+}
+
+func isEmpty(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func isDefault(path string, v interface{}) bool {
+	for _, d := range defaultValues[path] {
+		if reflect.DeepEqual(d, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// diffValues records the changes needed to turn old into new.
+func diffValues(path string, old, new interface{}, changes *[]Change) {
+
+	switch n := new.(type) {
+
+	case map[string]interface{}:
+
+		o, ok := old.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(o)+len(n))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range n {
+			if _, ok := o[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+
+			switch {
+			case !inOld:
+				*changes = append(*changes, Change{Type: Added, Path: join(path, k), New: nv})
+			case !inNew:
+				*changes = append(*changes, Change{Type: Removed, Path: join(path, k), Old: ov})
+			case dynamicPorts[k] && nv == 0.0:
+				// Marathon picks the port
+			default:
+				diffValues(join(path, k), ov, nv, changes)
+			}
+		}
+		return
+
+	case []interface{}:
+
+		o, ok := old.([]interface{})
+		if !ok {
+			break
+		}
+
+		if diffById(path, o, n, changes) {
+			return
+		}
+
+		for i := 0; i < len(o) || i < len(n); i++ {
+
+			p := fmt.Sprintf("%s[%d]", path, i)
+
+			switch {
+			case i >= len(o):
+				*changes = append(*changes, Change{Type: Added, Path: p, New: n[i]})
+			case i >= len(n):
+				*changes = append(*changes, Change{Type: Removed, Path: p, Old: o[i]})
+			default:
+				diffValues(p, o[i], n[i], changes)
+			}
+		}
+		return
+
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, Change{Type: Modified, Path: path, Old: old, New: new})
+	}
+}
+
+// diffById compares lists of apps, groups or pods by their IDs, so a
+// change of order is not reported.  It returns false if the lists are not
+// made up of objects with IDs.
+func diffById(path string, old, new []interface{}, changes *[]Change) bool {
+
+	byId := func(list []interface{}) (map[string]interface{}, []string, bool) {
+		m := make(map[string]interface{})
+		var order []string
+		for _, v := range list {
+			id, ok := elementId(v)
+			if !ok {
+				return nil, nil, false
+			}
+			m[id] = v
+			order = append(order, id)
+		}
+		return m, order, true
+	}
+
+	o, oOrder, ok := byId(old)
+	if !ok {
+		return false
+	}
+	n, nOrder, ok := byId(new)
+	if !ok {
+		return false
+	}
+	if len(old) == 0 && len(new) == 0 {
+		return true
+	}
+
+	for _, id := range oOrder {
+		p := fmt.Sprintf("%s[%s]", path, id)
+		if nv, ok := n[id]; ok {
+			diffValues(p, o[id], nv, changes)
+		} else {
+			*changes = append(*changes, Change{Type: Removed, Path: p, Old: o[id]})
+		}
+	}
+
+	for _, id := range nOrder {
+		if _, ok := o[id]; !ok {
+			p := fmt.Sprintf("%s[%s]", path, id)
+			*changes = append(*changes, Change{Type: Added, Path: p, New: n[id]})
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,317 @@
+package marathon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//
+// Tests for comparing job definitions
+//
+
+var testLiveApp = `{"app": {
+	"id": "/old",
+	"cmd": "env && sleep 300",
+	"args": null,
+	"user": null,
+	"env": {"FOO": "bar", "OLD": "x"},
+	"instances": 1,
+	"cpus": 1,
+	"mem": 128,
+	"disk": 0,
+	"executor": "",
+	"constraints": [],
+	"uris": [],
+	"fetch": [],
+	"storeUrls": [],
+	"backoffSeconds": 1,
+	"backoffFactor": 1.15,
+	"maxLaunchDelaySeconds": 3600,
+	"container": {
+		"type": "DOCKER",
+		"volumes": [],
+		"docker": {
+			"image": "nginx:1.13",
+			"network": "BRIDGE",
+			"portMappings": [{"containerPort": 80, "hostPort": 0, "servicePort": 10001, "protocol": "tcp", "labels": {}}],
+			"privileged": false,
+			"parameters": [],
+			"forcePullImage": false
+		}
+	},
+	"healthChecks": [{
+		"path": "/health",
+		"protocol": "HTTP",
+		"portIndex": 0,
+		"gracePeriodSeconds": 300,
+		"intervalSeconds": 60,
+		"timeoutSeconds": 20,
+		"maxConsecutiveFailures": 3,
+		"ignoreHttp1xx": false
+	}],
+	"readinessChecks": [],
+	"dependencies": [],
+	"upgradeStrategy": {"minimumHealthCapacity": 1, "maximumOverCapacity": 1},
+	"labels": {},
+	"acceptedResourceRoles": null,
+	"ipAddress": null,
+	"version": "2016-05-04T10:15:05.187Z",
+	"residency": null,
+	"secrets": {},
+	"taskKillGracePeriodSeconds": null,
+	"ports": [10001],
+	"portDefinitions": [{"port": 10001, "protocol": "tcp", "labels": {}}],
+	"requirePorts": false,
+	"versionInfo": {"lastScalingAt": "2016-05-04T10:15:05.187Z", "lastConfigChangeAt": "2016-05-04T10:15:05.187Z"},
+	"tasksStaged": 0,
+	"tasksRunning": 1,
+	"tasksHealthy": 1,
+	"tasksUnhealthy": 0,
+	"deployments": [],
+	"tasks": [{"id": "old.6e5a8bf4-11e6-a4b1-0242ac110002"}]
+}}`
+
+var testLiveGroup = `{
+	"id": "/product",
+	"version": "2016-05-04T10:15:05.187Z",
+	"dependencies": [],
+	"groups": [],
+	"apps": [
+		{"id": "/product/b", "cmd": "sleep 300", "instances": 1, "version": "2016-05-04T10:15:05.187Z", "labels": {}},
+		{"id": "/product/a", "cmd": "sleep 300", "instances": 2, "version": "2016-05-04T10:15:05.187Z", "labels": {}}
+	]
+}`
+
+func diffServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case appPath + "/old":
+			fmt.Fprint(w, testLiveApp)
+		case groupPath + "/product":
+			fmt.Fprint(w, testLiveGroup)
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+}
+
+func TestDiffUnchanged(t *testing.T) {
+
+	ts := diffServer()
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	j, err := NewJob([]byte(`{
+		"id": "old",
+		"cmd": "env && sleep 300",
+		"env": {"FOO": "bar", "OLD": "x"},
+		"container": {
+			"docker": {
+				"image": "nginx:1.13",
+				"network": "BRIDGE",
+				"portMappings": [{"containerPort": 80, "hostPort": 0, "servicePort": 0}]
+			}
+		},
+		"healthChecks": [{"path": "/health"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := c.Diff(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, changes)
+}
+
+func TestDiffChanged(t *testing.T) {
+
+	ts := diffServer()
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	j, err := NewJob([]byte(`{
+		"id": "/old",
+		"cmd": "env && sleep 300",
+		"instances": 3,
+		"env": {"FOO": "baz", "NEW": "y"},
+		"container": {
+			"docker": {
+				"image": "nginx:1.15",
+				"network": "BRIDGE",
+				"portMappings": [{"containerPort": 80, "hostPort": 0}]
+			}
+		},
+		"healthChecks": [{"path": "/health"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := c.Diff(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+
+	assert.Equal(t, []string{
+		`~ container.docker.image: "nginx:1.13" => "nginx:1.15"`,
+		`~ env.FOO: "bar" => "baz"`,
+		`+ env.NEW: "y"`,
+		`- env.OLD: "x"`,
+		`~ instances: 1 => 3`,
+	}, lines)
+}
+
+func TestDiffGroup(t *testing.T) {
+
+	ts := diffServer()
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	// Apps are matched by ID, whatever the order
+	j, err := NewJob([]byte(`{
+		"id": "/product",
+		"apps": [
+			{"id": "a", "cmd": "sleep 300", "instances": 2},
+			{"id": "/product/b", "cmd": "sleep 600"},
+			{"id": "c", "cmd": "sleep 300"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := c.Diff(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, `~ apps[/product/b].cmd: "sleep 300" => "sleep 600"`, changes[0].String())
+		assert.Equal(t, Added, changes[1].Type)
+		assert.Equal(t, "apps[/product/c]", changes[1].Path)
+	}
+}
+
+func TestDiffSameName(t *testing.T) {
+
+	// An app named after its group
+	j, err := NewJob([]byte(`{"id": "product/web", "apps": [{"id": "web", "cmd": "sleep 300"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var live map[string]interface{}
+	err = json.Unmarshal([]byte(`{
+		"id": "/product/web",
+		"apps": [{"id": "/product/web/web", "cmd": "sleep 300"}]
+	}`), &live)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desired, err := jobDefinition(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, diffDefinitions(j, live, desired))
+}
+
+func TestDiffNewJob(t *testing.T) {
+
+	ts := diffServer()
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	j, err := NewJob([]byte(testNewApp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := c.Diff(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, changes, 3)
+	for _, change := range changes {
+		assert.Equal(t, Added, change.Type)
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+
+	j, err := NewJob([]byte(`{"id": "/hashed", "cmd": "sleep 300"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = j.StampHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := j.Hash()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case appPath + "/old":
+			fmt.Fprint(w, testLiveApp)
+		case appPath + "/hashed":
+			// Differs from the job, but the hash is trusted
+			fmt.Fprintf(w, `{"app": {"id": "/hashed", "cmd": "sleep 600", "labels": {"%s": "%s"}}}`, HashLabel, hash)
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	unchanged, err := c.Unchanged(context.Background(), j)
+	if assert.NoError(t, err) {
+		assert.True(t, unchanged, "Hash label should match")
+	}
+
+	// Without a hash the definitions are compared
+	j, err = NewJob([]byte(`{"id": "/old", "cmd": "env && sleep 300", "env": {"FOO": "bar", "OLD": "x"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unchanged, err = c.Unchanged(context.Background(), j)
+	if assert.NoError(t, err) {
+		assert.False(t, unchanged, "Container and health checks were removed")
+	}
+
+	// New jobs are never unchanged
+	j, err = NewJob([]byte(testNewApp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unchanged, err = c.Unchanged(context.Background(), j)
+	if assert.NoError(t, err) {
+		assert.False(t, unchanged)
+	}
+}
@@ -0,0 +1,357 @@
+package marathon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+//
+// Marathon API support
+//
+
+// Raw event from the SSE stream
+type RawEvent struct {
+	Id   string
+	Name string
+	Data []byte
+}
+
+// ReconnectedEvent is sent by EventListener after the event stream has
+// been reopened.  Events may have been lost while it was down.
+const ReconnectedEvent = "event_stream_reconnected"
+
+type Timestamp string
+
+func (t Timestamp) String() string {
+	parsed, err := time.Parse(time.RFC3339, string(t))
+	if err != nil {
+		return ""
+	}
+	return parsed.String()
+}
+
+func (t Timestamp) Time() time.Time {
+	parsed, err := time.Parse(time.RFC3339, string(t))
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
+
+// EventBus parses raw events from in, and sends every event we know how
+// to handle to out.  out is closed once in is closed or the context is
+// cancelled.
+func (c *Client) EventBus(ctx context.Context, in <-chan RawEvent, out chan<- Event) {
+
+	defer close(out)
+
+	for raw := range in {
+
+		e := new(Event)
+		err := e.Unmarshal(raw)
+
+		switch {
+
+		case err != nil && err.Error() == "Unhandled event":
+			continue
+
+		case err != nil:
+			c.Logger.Println("Error parsing event:", err, raw.Data)
+			continue
+
+		default:
+			select {
+			case out <- *e:
+			case <-ctx.Done():
+				return
+			}
+
+		}
+	}
+}
+
+// Event is the main event container
+type Event struct {
+	Name                   string
+	ApiPostEvent           ApiPostEvent
+	AddHealthCheck         AddHealthCheck
+	FailedHealthCheck      FailedHealthCheck
+	HealthStatusChanged    HealthStatusChanged
+	GroupChangeSuccess     GroupChangeSuccess
+	GroupChangeFailed      GroupChangeFailed
+	DeploymentStatus       DeploymentStatus
+	MesosStatusUpdateEvent MesosStatusUpdateEvent
+	InstanceChanged        InstanceChanged
+	InstanceHealthChanged  InstanceHealthChanged
+}
+
+type EventCommon struct {
+	EventType string
+	Timestamp Timestamp
+}
+
+func (e EventCommon) Type() string {
+	return e.EventType
+}
+
+func (e EventCommon) Date() time.Time {
+	return e.Timestamp.Time()
+}
+
+// POST event
+type ApiPostEvent struct {
+	ClientIp      string
+	Uri           string
+	AppDefinition map[string]interface{}
+	EventCommon
+}
+
+// Health check events
+type AddHealthCheck struct {
+	AppId       string
+	Version     Timestamp
+	HealthCheck map[string]interface{}
+	EventCommon
+}
+
+type FailedHealthCheck struct {
+	AppId       string
+	TaskId      string
+	HealthCheck map[string]interface{}
+	EventCommon
+}
+
+type HealthStatusChanged struct {
+	AppId   string
+	TaskId  string
+	Version Timestamp
+	Alive   bool
+	EventCommon
+}
+
+// Group events
+type GroupChangeSuccess struct {
+	GroupId string
+	Version string
+	EventCommon
+}
+
+type GroupChangeFailed struct {
+	GroupId string
+	Version string
+	Reason  string
+	EventCommon
+}
+
+// Action is one step of a deployment plan, on either an app or a pod.
+type Action struct {
+	Action string
+	App    string
+	Pod    string
+}
+
+// Target returns the ID of the app or pod the action applies to.
+func (a Action) Target() string {
+	if a.Pod != "" {
+		return a.Pod
+	}
+	return a.App
+}
+
+type DeploymentPlan struct {
+	Id       string
+	Original map[string]interface{}
+	Target   map[string]interface{}
+	Steps    []DeploymentStep
+	Version  Timestamp
+}
+
+// DeploymentStep is a set of actions run together, the deployment moving
+// on to the next step once they have all finished.
+type DeploymentStep struct {
+	Actions []Action
+}
+
+// UnmarshalJSON also accepts the format used by older versions of
+// Marathon, where each step is a single action.
+func (s *DeploymentStep) UnmarshalJSON(data []byte) error {
+
+	var step struct {
+		Actions *[]Action
+	}
+	err := json.Unmarshal(data, &step)
+	if err != nil {
+		return err
+	}
+
+	if step.Actions != nil {
+		s.Actions = *step.Actions
+		return nil
+	}
+
+	var a Action
+	err = json.Unmarshal(data, &a)
+	s.Actions = []Action{a}
+	return err
+}
+
+// planActions lists the actions of every step of a plan.
+func planActions(steps []DeploymentStep) []Action {
+	actions := make([]Action, 0)
+	for i := range steps {
+		actions = append(actions, steps[i].Actions...)
+	}
+	return actions
+}
+
+// Deployment is an in-progress deployment, as listed by /v2/deployments
+type Deployment struct {
+	Id             string
+	Version        Timestamp
+	AffectedApps   []string
+	AffectedPods   []string
+	CurrentActions []Action
+	CurrentStep    int
+	TotalSteps     int
+	Steps          []DeploymentStep
+}
+
+type DeploymentStatus struct {
+	Id          string
+	Plan        DeploymentPlan
+	CurrentStep struct {
+		Actions []StepAction
+	}
+	EventCommon
+}
+
+// StepAction is an action of the current step of a deployment.
+type StepAction struct {
+	Type string
+	App  string
+	Pod  string
+}
+
+// UnmarshalJSON also accepts the action type as "action", as used by
+// newer versions of Marathon.
+func (a *StepAction) UnmarshalJSON(data []byte) error {
+
+	type plain StepAction
+	var v struct {
+		plain
+		Action string
+	}
+
+	err := json.Unmarshal(data, &v)
+	*a = StepAction(v.plain)
+	if a.Type == "" {
+		a.Type = v.Action
+	}
+	return err
+}
+
+// Target returns the ID of the app or pod the action applies to.
+func (a StepAction) Target() string {
+	if a.Pod != "" {
+		return a.Pod
+	}
+	return a.App
+}
+
+// Mesos events
+type MesosStatusUpdateEvent struct {
+	SlaveId    string
+	TaskId     string
+	TaskStatus string
+	Message    string
+	AppId      string
+	Host       string
+	Ports      []int
+	Version    string
+	EventCommon
+}
+
+// Instance events, sent for pods
+type InstanceChanged struct {
+	InstanceId     string
+	Condition      string
+	RunSpecId      string
+	RunSpecVersion Timestamp
+	AgentId        string
+	Host           string
+	EventCommon
+}
+
+type InstanceHealthChanged struct {
+	InstanceId     string
+	RunSpecId      string
+	RunSpecVersion Timestamp
+	Healthy        *bool
+	EventCommon
+}
+
+func (e *Event) Unmarshal(in RawEvent) (err error) {
+
+	if in.Name == "" || len(in.Data) == 0 {
+		return errors.New("Bad event object")
+	}
+
+	e.Name = strings.TrimRight(in.Name, "\r\n")
+
+	switch e.Name {
+
+	case "api_post_event":
+		err = json.Unmarshal(in.Data, &e.ApiPostEvent)
+
+	case "add_health_check_event":
+		err = json.Unmarshal(in.Data, &e.AddHealthCheck)
+
+	case "failed_health_check_event":
+		err = json.Unmarshal(in.Data, &e.FailedHealthCheck)
+
+	case "health_status_changed_event":
+		err = json.Unmarshal(in.Data, &e.HealthStatusChanged)
+
+	case "group_change_success":
+		err = json.Unmarshal(in.Data, &e.GroupChangeSuccess)
+
+	case "group_change_failed":
+		err = json.Unmarshal(in.Data, &e.GroupChangeFailed)
+
+	case "deployment_success":
+		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+
+	case "deployment_failed":
+		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+
+	case "deployment_info":
+		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+
+	case "deployment_step_success":
+		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+
+	case "deployment_step_failure":
+		err = json.Unmarshal(in.Data, &e.DeploymentStatus)
+
+	case "status_update_event":
+		err = json.Unmarshal(in.Data, &e.MesosStatusUpdateEvent)
+
+	case "instance_changed_event":
+		err = json.Unmarshal(in.Data, &e.InstanceChanged)
+
+	case "instance_health_changed_event":
+		err = json.Unmarshal(in.Data, &e.InstanceHealthChanged)
+
+	case ReconnectedEvent:
+		// Nothing to parse
+
+	default:
+		err = errors.New("Unhandled event")
+
+	}
+
+	return
+}
@@ -1,6 +1,8 @@
-package main
+package marathon
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"regexp"
@@ -330,6 +332,26 @@ var deployment_step_failure = `{
   }
 }`
 
+var instance_changed_event = `{
+  "eventType": "instance_changed_event",
+  "timestamp": "2017-03-01T23:29:30.158Z",
+  "instanceId": "product_pod.instance-9e9ea1a4-fe9f-11e6-8b9e-02d6b4c6e2d1",
+  "condition": "Running",
+  "runSpecId": "/product/pod",
+  "agentId": "20170301-054127-177048842-5050-1494-S0",
+  "host": "slave-1234.acme.org",
+  "runSpecVersion": "2017-03-01T23:24:14.846Z"
+}`
+
+var instance_health_changed_event = `{
+  "eventType": "instance_health_changed_event",
+  "timestamp": "2017-03-01T23:29:30.158Z",
+  "instanceId": "product_pod.instance-9e9ea1a4-fe9f-11e6-8b9e-02d6b4c6e2d1",
+  "runSpecId": "/product/pod",
+  "runSpecVersion": "2017-03-01T23:24:14.846Z",
+  "healthy": false
+}`
+
 var event_tests = map[string]string{
 	"api_post_event":              api_post_event,
 	"status_update_event":         status_update_event,
@@ -344,6 +366,9 @@ var event_tests = map[string]string{
 	"deployment_info":             deployment_info,
 	"deployment_step_success":     deployment_step_success,
 	"deployment_step_failure":     deployment_step_failure,
+
+	"instance_changed_event":        instance_changed_event,
+	"instance_health_changed_event": instance_health_changed_event,
 }
 
 var re *regexp.Regexp
@@ -471,6 +496,23 @@ func TestDeploymentInfoEvent(t *testing.T) {
 	}
 
 	assert.Equal(t, "deployment_info", e.DeploymentStatus.EventType)
+	assert.Equal(t, []DeploymentStep{{Actions: []Action{{Action: "ScaleApplication", App: "/my-app"}}}}, e.DeploymentStatus.Plan.Steps)
+}
+
+func TestDeploymentStepFormats(t *testing.T) {
+
+	// Newer versions of Marathon group the actions of each step
+	var status DeploymentStatus
+	err := json.Unmarshal([]byte(`{
+		"plan": {"steps": [{"actions": [{"action": "StartApplication", "app": "/a"}, {"action": "StartApplication", "app": "/b"}]}]},
+		"currentStep": {"actions": [{"action": "StartApplication", "app": "/a"}]}
+	}`), &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []DeploymentStep{{Actions: []Action{{Action: "StartApplication", App: "/a"}, {Action: "StartApplication", App: "/b"}}}}, status.Plan.Steps)
+	assert.Equal(t, []StepAction{{Type: "StartApplication", App: "/a"}}, status.CurrentStep.Actions)
 }
 
 func TestDeploymentStepSuccessEvent(t *testing.T) {
@@ -490,3 +532,56 @@ func TestDeploymentStepFailureEvent(t *testing.T) {
 
 	assert.Equal(t, "deployment_step_failure", e.DeploymentStatus.EventType)
 }
+
+func TestInstanceChangedEvent(t *testing.T) {
+	e, err := runEvent("instance_changed_event")
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "instance_changed_event", e.InstanceChanged.EventType)
+	assert.Equal(t, "/product/pod", e.InstanceChanged.RunSpecId)
+	assert.Equal(t, "Running", e.InstanceChanged.Condition)
+}
+
+func TestInstanceHealthChangedEvent(t *testing.T) {
+	e, err := runEvent("instance_health_changed_event")
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "instance_health_changed_event", e.InstanceHealthChanged.EventType)
+	assert.Equal(t, "/product/pod", e.InstanceHealthChanged.RunSpecId)
+	if assert.NotNil(t, e.InstanceHealthChanged.Healthy) {
+		assert.False(t, *e.InstanceHealthChanged.Healthy)
+	}
+}
+
+func TestEventBus(t *testing.T) {
+
+	var raw RawEvent
+	raw.Name = "api_post_event"
+	raw.Data = []byte(re.ReplaceAllString(event_tests["api_post_event"], ""))
+
+	in := make(chan RawEvent)
+	out := make(chan Event)
+
+	c := testClient("http://localhost")
+	go c.EventBus(context.Background(), in, out)
+
+	select {
+
+	case parsed, ok := <-out:
+		if ok {
+			assert.Equal(t, "api_post_event", parsed.ApiPostEvent.EventType)
+			assert.Equal(t, "0:0:0:0:0:0:0:1", parsed.ApiPostEvent.ClientIp)
+			assert.Equal(t, "2014-03-01 23:29:30.158 +0000 UTC", parsed.ApiPostEvent.Timestamp.String())
+		}
+
+	default:
+		in <- raw
+		close(in)
+
+	}
+
+}
@@ -0,0 +1,96 @@
+package marathon
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+//
+// Round-tripping of JSON fields we don't have types for
+//
+
+// Extra holds JSON fields of a definition that have no matching struct
+// field, so they are sent back to Marathon unchanged.
+type Extra map[string]json.RawMessage
+
+// unmarshalExtra decodes data into v, which must be a pointer to a struct
+// without its own UnmarshalJSON method, and stores any unknown fields in
+// extra.
+func unmarshalExtra(data []byte, v interface{}, extra *Extra) error {
+
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return err
+	}
+
+	var all map[string]json.RawMessage
+	err = json.Unmarshal(data, &all)
+	if err != nil {
+		return err
+	}
+
+	known := jsonFields(reflect.TypeOf(v).Elem())
+
+	*extra = nil
+	for k := range all {
+		if known[strings.ToLower(k)] {
+			continue
+		}
+		if *extra == nil {
+			*extra = make(Extra)
+		}
+		(*extra)[k] = all[k]
+	}
+
+	return nil
+}
+
+// marshalExtra encodes v, which must not have its own MarshalJSON method,
+// and adds the fields in extra.
+func marshalExtra(v interface{}, extra Extra) ([]byte, error) {
+
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var all map[string]json.RawMessage
+	err = json.Unmarshal(data, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range extra {
+		if _, ok := all[k]; !ok {
+			all[k] = extra[k]
+		}
+	}
+
+	return json.Marshal(all)
+}
+
+// jsonFields returns the lower cased JSON names of the fields of a struct,
+// as encoding/json matches them case insensitively.
+func jsonFields(t reflect.Type) map[string]bool {
+
+	fields := make(map[string]bool)
+
+	for i := 0; i < t.NumField(); i++ {
+
+		f := t.Field(i)
+
+		name := f.Name
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if n := strings.Split(tag, ",")[0]; n != "" {
+			name = n
+		}
+
+		fields[strings.ToLower(name)] = true
+	}
+
+	return fields
+}
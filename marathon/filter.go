@@ -0,0 +1,190 @@
+package marathon
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+//
+// Summarising and filtering events of any type
+//
+
+// EventSummary holds the fields of an event that say what it is about,
+// found in any type of event.
+type EventSummary struct {
+	Type         string
+	Timestamp    Timestamp
+	Apps         []string
+	DeploymentId string
+	TaskId       string
+	TaskStatus   string
+	Host         string
+	Message      string
+}
+
+// Keys holding the ID of an app, pod or group
+var appKeys = map[string]bool{
+	"appId":     true,
+	"runSpecId": true,
+	"groupId":   true,
+	"app":       true,
+	"pod":       true,
+}
+
+// Keys of deployment plans holding whole group trees, which would make a
+// deployment look like it affects every app
+var treeKeys = map[string]bool{
+	"original": true,
+	"target":   true,
+}
+
+// SummariseEvent finds the apps, deployment and task an event is about.
+func SummariseEvent(raw RawEvent) (s EventSummary, err error) {
+
+	var data map[string]interface{}
+	err = json.Unmarshal(raw.Data, &data)
+	if err != nil {
+		return
+	}
+
+	s.Type = raw.Name
+	if s.Type == "" {
+		s.Type = stringField(data, "eventType")
+	}
+	s.Timestamp = Timestamp(stringField(data, "timestamp"))
+
+	s.TaskId = stringField(data, "taskId")
+	if s.TaskId == "" {
+		s.TaskId = stringField(data, "instanceId")
+	}
+	s.TaskStatus = stringField(data, "taskStatus")
+	if s.TaskStatus == "" {
+		s.TaskStatus = stringField(data, "condition")
+	}
+	s.Host = stringField(data, "host")
+	s.Message = stringField(data, "message")
+	if s.Message == "" {
+		s.Message = stringField(data, "reason")
+	}
+
+	if plan, ok := data["plan"].(map[string]interface{}); ok {
+		s.DeploymentId = stringField(plan, "id")
+	}
+	if s.DeploymentId == "" && strings.HasPrefix(s.Type, "deployment_") {
+		s.DeploymentId = stringField(data, "id")
+	}
+
+	apps := make(map[string]bool)
+	findApps(data, apps)
+
+	if def, ok := data["appDefinition"].(map[string]interface{}); ok {
+		if id := stringField(def, "id"); id != "" {
+			apps[absoluteId(id, "")] = true
+		}
+	}
+
+	for app := range apps {
+		s.Apps = append(s.Apps, app)
+	}
+	sort.Strings(s.Apps)
+
+	return
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// findApps collects the IDs of apps, pods and groups anywhere in an event.
+func findApps(v interface{}, apps map[string]bool) {
+	switch v := v.(type) {
+
+	case map[string]interface{}:
+		for k, child := range v {
+			if treeKeys[k] {
+				continue
+			}
+			if id, ok := child.(string); ok && appKeys[k] && id != "" {
+				apps[id] = true
+				continue
+			}
+			findApps(child, apps)
+		}
+
+	case []interface{}:
+		for _, child := range v {
+			findApps(child, apps)
+		}
+
+	}
+}
+
+// EventFilter selects events by type, app, deployment or task state.
+// Empty fields match everything.
+type EventFilter struct {
+	Types []string
+
+	// Matches the app, and any app in a group, with an ID starting with it
+	AppPrefix string
+
+	DeploymentId string
+	TaskStates   []string
+
+	// Deployments seen affecting AppPrefix, as some events only give the
+	// deployment ID
+	deployments map[string]bool
+}
+
+// Match checks if an event passes the filter.  Events must be checked in
+// order, as deployment events are matched to apps by earlier events.
+func (f *EventFilter) Match(s EventSummary) bool {
+
+	// Checked first to see every deployment affecting the apps
+	if f.AppPrefix != "" && !f.matchApp(s) {
+		return false
+	}
+
+	if len(f.Types) > 0 && !contains(f.Types, s.Type) {
+		return false
+	}
+
+	if f.DeploymentId != "" && s.DeploymentId != f.DeploymentId {
+		return false
+	}
+
+	if len(f.TaskStates) > 0 && !contains(f.TaskStates, s.TaskStatus) {
+		return false
+	}
+
+	return true
+}
+
+func (f *EventFilter) matchApp(s EventSummary) bool {
+
+	prefix := absoluteId(f.AppPrefix, "")
+
+	for _, app := range s.Apps {
+		if strings.HasPrefix(absoluteId(app, ""), prefix) {
+			if s.DeploymentId != "" {
+				if f.deployments == nil {
+					f.deployments = make(map[string]bool)
+				}
+				f.deployments[s.DeploymentId] = true
+			}
+			return true
+		}
+	}
+
+	return s.DeploymentId != "" && f.deployments[s.DeploymentId]
+}
+
+func contains(list []string, s string) bool {
+	for i := range list {
+		if list[i] == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package marathon
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+//
+// Tests for summarising and filtering events
+//
+
+func summarise(t *testing.T, name string) EventSummary {
+	s, err := SummariseEvent(RawEvent{
+		Name: name,
+		Data: []byte(re.ReplaceAllString(event_tests[name], "")),
+	})
+	assert.NoError(t, err)
+	return s
+}
+
+func TestSummariseEvent(t *testing.T) {
+
+	s := summarise(t, "status_update_event")
+	assert.Equal(t, "status_update_event", s.Type)
+	assert.Equal(t, Timestamp("2014-03-01T23:29:30.158Z"), s.Timestamp)
+	assert.Equal(t, []string{"/my-app"}, s.Apps)
+	assert.Equal(t, "my-app_0-1396592784349", s.TaskId)
+	assert.Equal(t, "TASK_RUNNING", s.TaskStatus)
+	assert.Equal(t, "slave-1234.acme.org", s.Host)
+
+	// Only the apps in the steps, not every app in the group trees
+	s = summarise(t, "deployment_info")
+	assert.Equal(t, []string{"/my-app"}, s.Apps)
+	assert.Equal(t, "867ed450-f6a8-4d33-9b0e-e11c5513990b", s.DeploymentId)
+
+	s = summarise(t, "deployment_success")
+	assert.Empty(t, s.Apps)
+	assert.Equal(t, "867ed450-f6a8-4d33-9b0e-e11c5513990b", s.DeploymentId)
+
+	s = summarise(t, "api_post_event")
+	assert.Equal(t, []string{"/my-app"}, s.Apps)
+
+	s = summarise(t, "instance_health_changed_event")
+	assert.Equal(t, []string{"/product/pod"}, s.Apps)
+	assert.Equal(t, "product_pod.instance-9e9ea1a4-fe9f-11e6-8b9e-02d6b4c6e2d1", s.TaskId)
+
+	_, err := SummariseEvent(RawEvent{Name: "status_update_event", Data: []byte("{")})
+	assert.Error(t, err)
+}
+
+func TestEventFilter(t *testing.T) {
+
+	running := summarise(t, "status_update_event")
+
+	f := EventFilter{}
+	assert.True(t, f.Match(running))
+
+	f = EventFilter{Types: []string{"deployment_info", "status_update_event"}}
+	assert.True(t, f.Match(running))
+	f = EventFilter{Types: []string{"deployment_info"}}
+	assert.False(t, f.Match(running))
+
+	f = EventFilter{TaskStates: []string{"TASK_FAILED"}}
+	assert.False(t, f.Match(running))
+	f = EventFilter{TaskStates: []string{"TASK_FAILED", "TASK_RUNNING"}}
+	assert.True(t, f.Match(running))
+
+	f = EventFilter{AppPrefix: "/my"}
+	assert.True(t, f.Match(running))
+	f = EventFilter{AppPrefix: "my-app"}
+	assert.True(t, f.Match(running))
+	f = EventFilter{AppPrefix: "/other"}
+	assert.False(t, f.Match(running))
+
+	f = EventFilter{DeploymentId: "867ed450-f6a8-4d33-9b0e-e11c5513990b"}
+	assert.False(t, f.Match(running))
+	assert.True(t, f.Match(summarise(t, "deployment_info")))
+}
+
+func TestEventFilterDeploymentApps(t *testing.T) {
+
+	success := summarise(t, "deployment_success")
+
+	// The success event doesn't say which apps were deployed, so it only
+	// matches once the deployment has been seen
+	f := EventFilter{Types: []string{"deployment_success"}, AppPrefix: "/my-app"}
+	assert.False(t, f.Match(success))
+	assert.False(t, f.Match(summarise(t, "deployment_info")))
+	assert.True(t, f.Match(success))
+
+	f = EventFilter{Types: []string{"deployment_success"}, AppPrefix: "/other"}
+	assert.False(t, f.Match(summarise(t, "deployment_info")))
+	assert.False(t, f.Match(success))
+}
@@ -0,0 +1,28 @@
+package marathon
+
+//
+// Marathon group definitions
+//
+
+// Group is a Marathon group definition, holding apps, pods and nested
+// groups.
+type Group struct {
+	Id           string    `json:"id"`
+	Apps         *[]App    `json:"apps,omitempty"`
+	Groups       *[]Group  `json:"groups,omitempty"`
+	Pods         *[]Pod    `json:"pods,omitempty"`
+	Dependencies *[]string `json:"dependencies,omitempty"`
+	Version      string    `json:"version,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (g Group) MarshalJSON() ([]byte, error) {
+	type plain Group
+	return marshalExtra(plain(g), g.Extra)
+}
+
+func (g *Group) UnmarshalJSON(data []byte) error {
+	type plain Group
+	return unmarshalExtra(data, (*plain)(g), &g.Extra)
+}
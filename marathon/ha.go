@@ -0,0 +1,382 @@
+package marathon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Running against several Marathon instances
+//
+
+// pingTimeout bounds each check of an instance while looking for the leader
+const pingTimeout = 5 * time.Second
+
+// endpoints tracks which of several Marathon instances requests go to.
+type endpoints struct {
+	sync.Mutex
+
+	// Index in URLs of the instance in use
+	current int
+
+	// Set once the leader has been looked for
+	discovered bool
+
+	// host:port of the leader as last reported by /v2/leader
+	leader string
+}
+
+// parseURLs parses a comma separated list of Marathon URLs, defaulting the
+// scheme to http.
+func parseURLs(rawurls string) (list []*url.URL, err error) {
+
+	for _, rawurl := range strings.Split(rawurls, ",") {
+
+		rawurl = strings.TrimSpace(rawurl)
+		if rawurl == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(rawurl, "http") {
+			// default to http
+			rawurl = "http://" + rawurl
+		}
+
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, u)
+	}
+
+	if len(list) == 0 {
+		err = errors.New("Marathon URL is empty")
+	}
+	return
+}
+
+// base returns the URL of the instance in use.
+func (c *Client) base() *url.URL {
+
+	if len(c.URLs) < 2 {
+		return c.URL
+	}
+
+	c.ha.Lock()
+	defer c.ha.Unlock()
+	return c.URLs[c.ha.current]
+}
+
+// discover picks the instance to use if there are several, the first
+// time it is called.
+func (c *Client) discover(ctx context.Context) {
+
+	if len(c.URLs) < 2 {
+		return
+	}
+
+	c.ha.Lock()
+	done := c.ha.discovered
+	c.ha.Unlock()
+
+	if !done {
+		c.findLeader(ctx, indexOf(c.URLs, c.base()))
+	}
+}
+
+// failover switches to another instance after failed went away, if there
+// are several.  It reports whether requests now go somewhere else.
+func (c *Client) failover(ctx context.Context, failed *url.URL) bool {
+
+	if len(c.URLs) < 2 || failed == nil {
+		return false
+	}
+
+	// Another request may already have moved on
+	if c.base() == failed {
+		c.findLeader(ctx, indexOf(c.URLs, failed)+1)
+	}
+
+	return c.base() != failed
+}
+
+// findLeader checks each instance, starting with the one at index from,
+// and switches to the leader.  If the leader isn't one of URLs, such as when
+// it is known by another address, any live instance is used instead and
+// proxies requests to it.
+func (c *Client) findLeader(ctx context.Context, from int) {
+
+	var live *url.URL
+	var errs []string
+	down := make(map[*url.URL]bool)
+
+	for i := 0; i < len(c.URLs); i++ {
+
+		u := c.URLs[(from+i)%len(c.URLs)]
+
+		leader, err := c.probe(ctx, u)
+		if err != nil {
+			c.debugln("Marathon at", u, "unavailable:", err)
+			errs = append(errs, fmt.Sprintf("%s: %v", u.Host, err))
+			down[u] = true
+			continue
+		}
+
+		if live == nil {
+			live = u
+		}
+
+		if leader == "" {
+			continue
+		}
+		c.setLeader(leader)
+
+		// The others may not have noticed the leader has gone
+		for _, candidate := range c.URLs {
+			if sameHost(candidate, leader) && !down[candidate] {
+				c.use(candidate, "leader")
+				return
+			}
+		}
+	}
+
+	if live == nil {
+		c.Logger.Println("No Marathon instance available:", strings.Join(errs, ", "))
+		c.ha.Lock()
+		c.ha.discovered = true
+		c.ha.Unlock()
+		return
+	}
+
+	c.use(live, "instance")
+}
+
+// use switches requests to u.
+func (c *Client) use(u *url.URL, role string) {
+
+	c.ha.Lock()
+	changed := c.URLs[c.ha.current] != u || !c.ha.discovered
+	c.ha.current = indexOf(c.URLs, u)
+	c.ha.discovered = true
+	c.ha.Unlock()
+
+	if changed {
+		c.Logger.Println("Using Marathon", role, "at", u)
+	}
+}
+
+// setLeader records the leader an instance reported.
+func (c *Client) setLeader(hostport string) {
+	c.ha.Lock()
+	c.ha.leader = hostport
+	c.ha.Unlock()
+}
+
+// probing marks the context of requests made by probe.
+type probing struct{}
+
+// probe checks an instance is up with /ping, and asks it for the leader,
+// which is empty if there isn't one.
+func (c *Client) probe(ctx context.Context, u *url.URL) (leader string, err error) {
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, probing{}, true)
+
+	ping := *u
+	ping.Path = strings.TrimRight(u.Path, "/") + "/ping"
+
+	req, err := c.newRequest(ctx, "GET", &ping, nil)
+	if err != nil {
+		return
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = &httpError{StatusCode: resp.StatusCode, Status: resp.Status}
+		return
+	}
+
+	lookup := *u
+	lookup.Path = strings.TrimRight(u.Path, "/") + leaderPath
+
+	var info struct {
+		Leader string
+	}
+
+	req, err = c.newRequest(ctx, "GET", &lookup, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err = c.HTTPClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	// No leader elected yet, but the instance is up
+	if resp.StatusCode == 404 {
+		return "", nil
+	}
+	if resp.StatusCode != 200 {
+		err = &httpError{StatusCode: resp.StatusCode, Status: resp.Status}
+		return
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &info)
+	return info.Leader, err
+}
+
+// rebase moves a request URL built for one instance onto the instance in
+// use, if that has changed since.
+func (c *Client) rebase(u *url.URL) *url.URL {
+
+	to := c.base()
+
+	for _, from := range c.URLs {
+		if from == to || from.Host != u.Host || from.Scheme != u.Scheme {
+			continue
+		}
+
+		prefix := strings.TrimRight(from.Path, "/")
+		if !strings.HasPrefix(u.Path, prefix) {
+			continue
+		}
+
+		moved := *u
+		moved.Scheme = to.Scheme
+		moved.Host = to.Host
+		moved.Path = strings.TrimRight(to.Path, "/") + strings.TrimPrefix(u.Path, prefix)
+		return &moved
+	}
+
+	return u
+}
+
+// instanceOf finds which of URLs a request URL was built for.
+func (c *Client) instanceOf(u *url.URL) *url.URL {
+	for _, candidate := range c.URLs {
+		if candidate.Host == u.Host && candidate.Scheme == u.Scheme {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// checkRedirect keeps the credentials when a Marathon instance redirects a
+// request to the leader on another host, which the HTTP client would
+// otherwise drop.  They are only sent to the Marathon instances and the
+// leader they report, and never over plain HTTP when the request was made
+// over HTTPS.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+
+	first := via[0].URL
+	downgrade := first.Scheme == "https" && req.URL.Scheme != "https"
+
+	if downgrade || !c.trusted(req.Context(), req.URL, first) {
+		c.debugln("Not sending credentials on redirect to", req.URL.Host)
+		req.Header.Del("Authorization")
+		return nil
+	}
+
+	if req.Header.Get("Authorization") != "" {
+		return nil
+	}
+
+	c.debugln("Following redirect to", req.URL.Host)
+	return c.authorize(req)
+}
+
+// trusted checks if u is one of the Marathon instances, or the leader.  If
+// it isn't the leader last known, the instance the request was first sent
+// to is asked for the leader again, as it may have changed.
+func (c *Client) trusted(ctx context.Context, u, first *url.URL) bool {
+
+	for _, candidate := range c.URLs {
+		if sameHost(u, hostport(candidate)) {
+			return true
+		}
+	}
+
+	c.ha.Lock()
+	leader := c.ha.leader
+	c.ha.Unlock()
+
+	if leader != "" && sameHost(u, leader) {
+		return true
+	}
+
+	// Not while already looking for the leader
+	if ctx.Value(probing{}) != nil {
+		return false
+	}
+
+	from := c.instanceOf(first)
+	if from == nil {
+		from = c.base()
+	}
+
+	leader, err := c.probe(ctx, from)
+	if err != nil || leader == "" {
+		return false
+	}
+	c.setLeader(leader)
+
+	return sameHost(u, leader)
+}
+
+func indexOf(list []*url.URL, u *url.URL) int {
+	for i := range list {
+		if list[i] == u {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameHost checks if a URL is for the host:port Marathon reports as the
+// leader.
+func sameHost(u *url.URL, hp string) bool {
+	return u.Host == hp || hostport(u) == hp
+}
+
+// hostport returns the host and port of a URL, filling in the default port
+// for the scheme.
+func hostport(u *url.URL) string {
+
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,229 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// instance runs a fake Marathon that reports leader, or itself if empty,
+// and counts the API requests it gets.
+type instance struct {
+	*httptest.Server
+	leader   atomic.Value
+	requests int32
+}
+
+func newInstance() *instance {
+	i := new(instance)
+	i.leader.Store("")
+	i.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+
+		case "/ping":
+			fmt.Fprint(w, "pong")
+
+		case leaderPath:
+			leader := i.leader.Load().(string)
+			if leader == "" {
+				leader = strings.TrimPrefix(i.URL, "http://")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"leader": "%s"}`, leader)
+
+		case deploymentPath:
+			atomic.AddInt32(&i.requests, 1)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[]`)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	return i
+}
+
+func (i *instance) host() string {
+	return strings.TrimPrefix(i.URL, "http://")
+}
+
+func TestParseURLs(t *testing.T) {
+
+	list, err := parseURLs("marathon-1:8080, https://marathon-2:8443/marathon,")
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "http://marathon-1:8080", list[0].String())
+		assert.Equal(t, "https://marathon-2:8443/marathon", list[1].String())
+	}
+
+	_, err = parseURLs(" , ")
+	assert.Error(t, err)
+}
+
+func TestSameHost(t *testing.T) {
+
+	u, _ := url.Parse("http://marathon-1:8080")
+	assert.True(t, sameHost(u, "marathon-1:8080"))
+	assert.False(t, sameHost(u, "marathon-1:8081"))
+	assert.False(t, sameHost(u, "marathon-2:8080"))
+
+	u, _ = url.Parse("https://marathon-1")
+	assert.True(t, sameHost(u, "marathon-1:443"))
+	assert.False(t, sameHost(u, "marathon-1:80"))
+}
+
+func TestLeaderDiscovery(t *testing.T) {
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	follower := newInstance()
+	defer follower.Close()
+
+	leader := newInstance()
+	defer leader.Close()
+
+	follower.leader.Store(leader.host())
+
+	c := testClient(strings.Join([]string{down.URL, follower.URL, leader.URL}, ","))
+	c.Retry.Backoff = time.Millisecond
+
+	_, err := c.Deployments(context.Background())
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, 0, follower.requests)
+	assert.EqualValues(t, 1, leader.requests)
+}
+
+func TestFailover(t *testing.T) {
+
+	first := newInstance()
+	second := newInstance()
+	defer second.Close()
+
+	c := testClient(first.URL + "," + second.URL)
+	c.Retry.Backoff = time.Millisecond
+	ctx := context.Background()
+
+	_, err := c.Deployments(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, first.requests)
+
+	// The first goes away, and the second takes over
+	first.Close()
+
+	_, err = c.Deployments(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, second.requests)
+	assert.Equal(t, second.URL, c.endpoint("").String())
+}
+
+func TestFailoverWithoutRetries(t *testing.T) {
+
+	first := newInstance()
+	second := newInstance()
+	defer second.Close()
+
+	// Never retried, but the other instance is still tried
+	c := testClient(first.URL + "," + second.URL)
+	c.Retry = RetryPolicy{}
+	ctx := context.Background()
+
+	_, err := c.Deployments(ctx)
+	assert.NoError(t, err)
+
+	first.Close()
+
+	_, err = c.Deployments(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, second.requests)
+
+	// Only once, when every instance is down
+	second.Close()
+
+	_, err = c.Deployments(ctx)
+	assert.Error(t, err)
+}
+
+// redirectServer runs a Marathon instance that reports leader, and
+// redirects API requests to target.
+func redirectServer(leader, target string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+
+		case "/ping":
+			fmt.Fprint(w, "pong")
+
+		case leaderPath:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"leader": "%s"}`, leader)
+
+		default:
+			http.Redirect(w, r, target+r.URL.Path, http.StatusTemporaryRedirect)
+		}
+	}))
+}
+
+// authServer records the Authorization header of the last request.
+func authServer(auth *atomic.Value) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[]`)
+	}))
+}
+
+func TestRedirectKeepsAuth(t *testing.T) {
+
+	var auth atomic.Value
+
+	leader := authServer(&auth)
+	defer leader.Close()
+
+	// A different host name, so the HTTP client would drop the credentials
+	target := strings.Replace(leader.URL, "127.0.0.1", "localhost", 1)
+
+	proxy := redirectServer(strings.TrimPrefix(target, "http://"), target)
+	defer proxy.Close()
+
+	c := testClient(proxy.URL)
+	c.SetBasicAuth("user", "pass")
+
+	_, err := c.Deployments(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Basic dXNlcjpwYXNz", auth.Load())
+}
+
+func TestRedirectElsewhereDropsAuth(t *testing.T) {
+
+	var auth atomic.Value
+
+	other := authServer(&auth)
+	defer other.Close()
+
+	leader := newInstance()
+	defer leader.Close()
+
+	// Neither an instance nor the leader, on another host or the same one
+	for _, target := range []string{strings.Replace(other.URL, "127.0.0.1", "localhost", 1), other.URL} {
+
+		proxy := redirectServer(leader.host(), target)
+
+		c := testClient(proxy.URL)
+		c.SetBasicAuth("user", "secret")
+
+		_, err := c.Deployments(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "", auth.Load(), "Credentials sent to %s", target)
+
+		proxy.Close()
+	}
+}
@@ -0,0 +1,250 @@
+package marathon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//
+// Verify apps stay healthy once a deployment has finished
+//
+
+// Stability configures WaitStable.
+type Stability struct {
+	// Healthy tasks each app needs on its new version.  Zero means all
+	// of the app's instances.
+	Instances int
+
+	// How long the apps must stay healthy for
+	Window time.Duration
+
+	// How long the apps have to become healthy before the window starts,
+	// after which WaitStable fails.  Zero means the same as Window.
+	Grace time.Duration
+
+	// Time between checks of the apps, DefaultPollInterval if zero
+	Interval time.Duration
+}
+
+// appTasks is the part of /v2/apps/{id}?embed=apps.tasks used to check the
+// health of an app's tasks.
+type appTasks struct {
+	App struct {
+		Id          string
+		Version     Timestamp
+		VersionInfo struct {
+			LastConfigChangeAt Timestamp
+		}
+		Instances    int
+		HealthChecks []interface{}
+		Tasks        []struct {
+			Id                 string
+			State              string
+			Version            Timestamp
+			HealthCheckResults []struct {
+				Alive bool
+			}
+		}
+	}
+}
+
+// configVersion is the version of the app's current configuration.
+// Scaling gives the app a new version, but tasks keep the one they were
+// launched with, so any task from this version on is up to date.
+func (a *appTasks) configVersion() Timestamp {
+	if a.App.VersionInfo.LastConfigChangeAt != "" {
+		return a.App.VersionInfo.LastConfigChangeAt
+	}
+	return a.App.Version
+}
+
+// current checks if a task launched at version runs the configuration
+// from config.
+func current(version, config Timestamp) bool {
+	return version == config || !version.Time().Before(config.Time())
+}
+
+// healthy counts the tasks of the app on its current configuration that
+// are running, and passing their health checks if it has any.
+func (a *appTasks) healthy() (n int) {
+	config := a.configVersion()
+	for _, task := range a.App.Tasks {
+		if !current(task.Version, config) {
+			continue
+		}
+		if task.State != "" && task.State != "TASK_RUNNING" {
+			continue
+		}
+		if len(a.App.HealthChecks) > 0 {
+			if len(task.HealthCheckResults) < len(a.App.HealthChecks) {
+				continue
+			}
+			alive := true
+			for _, r := range task.HealthCheckResults {
+				alive = alive && r.Alive
+			}
+			if !alive {
+				continue
+			}
+		}
+		n++
+	}
+	return
+}
+
+// WaitStable waits until every app in a job has enough healthy tasks on
+// its new version, and they stay healthy for the stability window.  It is
+// meant to be called once a deployment has finished, as Marathon can
+// report success while apps are still failing their health checks.  If
+// health regresses during the window, or the apps aren't healthy within the
+// grace period, an error is returned.
+//
+// Apps are checked by polling, and events, which may be nil, are watched
+// for failed health checks in between.  Pods are not checked.
+func (c *Client) WaitStable(ctx context.Context, job Job, s Stability, events <-chan Event) error {
+
+	apps := job.Apps()
+	if len(apps) == 0 {
+		return nil
+	}
+
+	if s.Interval <= 0 {
+		s.Interval = DefaultPollInterval
+	}
+	if s.Grace <= 0 {
+		s.Grace = s.Window
+	}
+
+	c.debugln("Waiting for", strings.Join(apps, ", "), "to be stable for", s.Window)
+
+	// Configuration versions being checked, by app
+	versions := make(map[string]Timestamp)
+
+	// Set once every app is healthy
+	var stable time.Time
+
+	tracking := time.Now()
+
+	// Last time the apps could be checked
+	checked := tracking
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+
+		problems, err := c.checkHealthy(ctx, apps, s.Instances, versions)
+		if err == nil {
+			checked = time.Now()
+		}
+
+		switch {
+
+		case err != nil && ctx.Err() != nil:
+
+		// Errors that persist through the grace period are failures too
+		case err != nil && time.Since(checked) >= s.Grace:
+			return fmt.Errorf("Unable to check app health for %s: %s", roundDuration(time.Since(checked)), err)
+
+		case err != nil:
+			c.Logger.Println("Unable to check app health:", err)
+
+		case len(problems) > 0 && !stable.IsZero():
+			return fmt.Errorf("%s:\n%s", "Health regressed after deployment", strings.Join(problems, "\n"))
+
+		case len(problems) > 0 && time.Since(tracking) >= s.Grace:
+			return fmt.Errorf("Apps not healthy %s after deployment:\n%s",
+				roundDuration(time.Since(tracking)), strings.Join(problems, "\n"))
+
+		case len(problems) > 0:
+			c.debugln(strings.Join(problems, "\n"))
+
+		case stable.IsZero():
+			c.debugln("Apps healthy, checking they stay healthy for", s.Window)
+			stable = time.Now()
+
+		case time.Since(stable) >= s.Window:
+			c.debugln("Apps stable for", time.Since(stable))
+			return nil
+
+		}
+
+		// Watch for failed health checks until the next check
+	Wait:
+		for {
+			select {
+
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					reason := "Timed out after " + time.Since(tracking).String() + " waiting for apps to be healthy"
+					if len(problems) > 0 {
+						reason += ":\n" + strings.Join(problems, "\n")
+					}
+					return errors.New(reason)
+				}
+				return ctx.Err()
+
+			case e, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+
+				hc := e.HealthStatusChanged
+				if e.Name != "health_status_changed_event" || hc.Alive || stable.IsZero() {
+					continue
+				}
+				if v, ok := versions[hc.AppId]; ok && current(hc.Version, v) {
+					return fmt.Errorf("%s:\n%s", "Health regressed after deployment",
+						fmt.Sprintf("Application: %s\nTask %s failed its health check", hc.AppId, hc.TaskId))
+				}
+
+			case <-ticker.C:
+				break Wait
+
+			}
+		}
+	}
+}
+
+// checkHealthy returns a description of any of the apps without enough
+// healthy tasks on their current version, recording the versions checked.
+func (c *Client) checkHealthy(ctx context.Context, apps []string, instances int, versions map[string]Timestamp) (problems []string, err error) {
+
+	for _, app := range apps {
+
+		u := c.endpoint(appPath + app)
+		u.RawQuery = "embed=apps.tasks"
+
+		var state appTasks
+
+		status, err := c.getJSON(ctx, u, &state)
+		if err != nil {
+			return nil, err
+		}
+		if status == 404 {
+			problems = append(problems, fmt.Sprintf("Application: %s\nNot found", app))
+			continue
+		}
+		if status != 200 {
+			return nil, fmt.Errorf("Unable to check %s, HTTP status code: %d", app, status)
+		}
+
+		versions[app] = state.configVersion()
+
+		want := instances
+		if want <= 0 || want > state.App.Instances {
+			want = state.App.Instances
+		}
+
+		if n := state.healthy(); n < want {
+			problems = append(problems, fmt.Sprintf("Application: %s\n%d/%d tasks healthy on version %s",
+				app, n, want, state.configVersion()))
+		}
+	}
+
+	return
+}
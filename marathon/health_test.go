@@ -0,0 +1,225 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var healthyApp = `{"app": {
+	"id": "/my-app",
+	"version": "2017-01-02T00:00:00.000Z",
+	"instances": 2,
+	"healthChecks": [{"protocol": "HTTP", "path": "/health"}],
+	"tasks": [
+		{"id": "t1", "state": "TASK_RUNNING", "version": "2017-01-02T00:00:00.000Z", "healthCheckResults": [{"alive": true}]},
+		{"id": "t2", "state": "TASK_RUNNING", "version": "2017-01-02T00:00:00.000Z", "healthCheckResults": [{"alive": %t}]},
+		{"id": "t0", "state": "TASK_RUNNING", "version": "2017-01-01T00:00:00.000Z", "healthCheckResults": [{"alive": true}]}
+	]
+}}`
+
+// healthServer serves an app whose second task is healthy for the given
+// range of checks.
+func healthServer(app string, from, to int32) *httptest.Server {
+
+	var count int32
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path != appPath+app || r.URL.Query().Get("embed") != "apps.tasks" {
+			http.Error(w, "Not found", 404)
+			return
+		}
+
+		n := atomic.AddInt32(&count, 1)
+		fmt.Fprintf(w, healthyApp, n >= from && n <= to)
+	}))
+}
+
+func TestWaitStable(t *testing.T) {
+
+	ts := healthServer("/my-app", 3, 1000)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Stability{Window: 30 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+	err = c.WaitStable(context.Background(), job, s, nil)
+	assert.NoError(t, err)
+
+	// One healthy instance is enough
+	ts2 := healthServer("/my-app", 0, 0)
+	defer ts2.Close()
+
+	c = testClient(ts2.URL)
+	s.Instances = 1
+
+	err = c.WaitStable(context.Background(), job, s, nil)
+	assert.NoError(t, err)
+}
+
+func TestWaitStableRegressed(t *testing.T) {
+
+	ts := healthServer("/product/my-app", 2, 3)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/product", "apps": [{"id": "my-app"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Stability{Window: time.Second, Interval: 5 * time.Millisecond}
+
+	err = c.WaitStable(context.Background(), job, s, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Health regressed")
+		assert.Contains(t, err.Error(), "1/2 tasks healthy")
+	}
+}
+
+func TestWaitStableEvents(t *testing.T) {
+
+	ts := healthServer("/my-app", 1, 1000)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan Event, 1)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+
+		var e Event
+		e.Name = "health_status_changed_event"
+		e.HealthStatusChanged.AppId = "/my-app"
+		e.HealthStatusChanged.TaskId = "t2"
+		e.HealthStatusChanged.Version = "2017-01-02T00:00:00.000Z"
+		ch <- e
+	}()
+
+	s := Stability{Window: time.Second, Interval: 5 * time.Millisecond}
+
+	err = c.WaitStable(context.Background(), job, s, ch)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Task t2 failed its health check")
+	}
+}
+
+func TestWaitStableNeverHealthy(t *testing.T) {
+
+	ts := healthServer("/my-app", 0, 0)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No deadline on the context, so only the grace period stops it
+	s := Stability{Window: time.Minute, Grace: 30 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+	err = c.WaitStable(context.Background(), job, s, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Apps not healthy")
+		assert.Contains(t, err.Error(), "1/2 tasks healthy")
+	}
+}
+
+func TestWaitStableScaled(t *testing.T) {
+
+	// Scaled from 2 to 3, so only the new task has the app's version
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"app": {
+			"id": "/my-app",
+			"version": "2017-01-03T00:00:00.000Z",
+			"versionInfo": {"lastScalingAt": "2017-01-03T00:00:00.000Z", "lastConfigChangeAt": "2017-01-02T00:00:00.000Z"},
+			"instances": 3,
+			"healthChecks": [{"protocol": "HTTP", "path": "/health"}],
+			"tasks": [
+				{"id": "t1", "state": "TASK_RUNNING", "version": "2017-01-02T00:00:00.000Z", "healthCheckResults": [{"alive": true}]},
+				{"id": "t2", "state": "TASK_RUNNING", "version": "2017-01-02T00:00:00.000Z", "healthCheckResults": [{"alive": true}]},
+				{"id": "t3", "state": "TASK_RUNNING", "version": "2017-01-03T00:00:00.000Z", "healthCheckResults": [{"alive": true}]},
+				{"id": "t0", "state": "TASK_RUNNING", "version": "2017-01-01T00:00:00.000Z", "healthCheckResults": [{"alive": true}]}
+			]
+		}}`)
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Stability{Window: 20 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+	err = c.WaitStable(context.Background(), job, s, nil)
+	assert.NoError(t, err)
+}
+
+func TestWaitStableMissing(t *testing.T) {
+
+	ts := healthServer("/other-app", 0, 1000)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Stability{Window: time.Minute, Grace: 30 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+	err = c.WaitStable(context.Background(), job, s, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Apps not healthy")
+		assert.Contains(t, err.Error(), "Application: /my-app\nNot found")
+	}
+}
+
+func TestWaitStableUnavailable(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unavailable", 503)
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Stability{Window: time.Minute, Grace: 30 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+	err = c.WaitStable(context.Background(), job, s, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Unable to check app health")
+	}
+}
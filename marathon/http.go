@@ -0,0 +1,493 @@
+package marathon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+//
+// HTTP sending and recieving
+//
+const (
+	eventPath = "/v2/events"
+	groupPath = "/v2/groups"
+	appPath   = "/v2/apps"
+	podPath   = "/v2/pods"
+	queuePath = "/v2/queue"
+
+	leaderPath     = "/v2/leader"
+	deploymentPath = "/v2/deployments"
+)
+
+const (
+	// Reconnection delay used until the server sends one
+	defaultRetry = time.Second
+
+	// Upper bound for the reconnection backoff
+	maxRetry = 30 * time.Second
+)
+
+// EventListener opens the Marathon event stream and sends every event
+// received to ch.  If the stream drops it is reopened with backoff, and
+// a ReconnectedEvent is sent so consumers can resync any state they may
+// have missed.  The channel is closed when the context is cancelled.
+//
+// If any event types are given, only those events are sent.  They are
+// filtered by the server where it supports it (Marathon 1.3+), and by the
+// client otherwise.
+func (c *Client) EventListener(ctx context.Context, ch chan<- RawEvent, types ...string) (err error) {
+
+	stream := eventStream{
+		retry:  defaultRetry,
+		types:  types,
+		filter: len(types) > 0,
+	}
+
+	resp, err := c.openEventStream(ctx, &stream)
+	if err != nil {
+		close(ch)
+		return
+	}
+
+	go func() {
+
+		defer close(ch)
+
+		for {
+
+			stream.read(ctx, resp.Body, ch)
+			resp.Body.Close()
+
+			delay := stream.retry
+
+			for {
+				if ctx.Err() != nil {
+					return
+				}
+
+				c.Logger.Println("Event stream lost, reconnecting in", delay)
+
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return
+				}
+
+				resp, err = c.openEventStream(ctx, &stream)
+				if err == nil {
+					break
+				}
+
+				c.debugln("Failed to reconnect to event stream:", err)
+
+				delay *= 2
+				if delay > maxRetry {
+					delay = maxRetry
+				}
+			}
+
+			c.debugln("Event stream reconnected")
+
+			select {
+			case ch <- RawEvent{Name: ReconnectedEvent, Data: []byte("{}")}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+	}()
+
+	return nil
+
+}
+
+// openEventStream connects to the event stream, falling back to client
+// side filtering if the server rejects the event_type parameter.
+func (c *Client) openEventStream(ctx context.Context, s *eventStream) (resp *http.Response, err error) {
+
+	resp, err = c.connectEventStream(ctx, s)
+
+	if herr, ok := err.(*httpError); ok && s.filter &&
+		(herr.StatusCode == 400 || herr.StatusCode == 422) {
+
+		c.debugln("Server side event filtering not supported, filtering events locally")
+		s.filter = false
+		resp, err = c.connectEventStream(ctx, s)
+	}
+
+	return
+}
+
+func (c *Client) connectEventStream(ctx context.Context, s *eventStream) (resp *http.Response, err error) {
+
+	u := c.endpoint(eventPath)
+	if s.filter {
+		q := u.Query()
+		for _, t := range s.types {
+			q.Add("event_type", t)
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	req, err := c.newRequest(ctx, "GET", u, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Add("Accept", "text/event-stream")
+
+	if s.lastId != "" {
+		req.Header.Set("Last-Event-ID", s.lastId)
+	}
+
+	resp, err = c.do(req, nil)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, &httpError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return
+}
+
+// httpError is an unexpected HTTP response from Marathon.
+type httpError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *httpError) Error() string {
+	return "Error, got response " + e.Status
+}
+
+// eventStream holds the state of the event stream that must survive a
+// reconnect.
+type eventStream struct {
+	lastId string
+	retry  time.Duration
+
+	// Event types wanted, all are sent if empty
+	types []string
+	// Set while the server is doing the filtering
+	filter bool
+}
+
+// wanted checks if an event should be passed on.  Events are always
+// checked, as older servers ignore the event_type parameter.
+func (s *eventStream) wanted(name string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for i := range s.types {
+		if s.types[i] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// read parses events from r until it fails or the context is cancelled.
+func (s *eventStream) read(ctx context.Context, r io.Reader, ch chan<- RawEvent) {
+
+	d := NewEventDecoder(r)
+	d.lastId = s.lastId
+
+	defer func() {
+		s.lastId = d.LastEventId()
+		if d.Retry() > 0 {
+			s.retry = d.Retry()
+		}
+	}()
+
+	for {
+
+		ev, err := d.Decode()
+		if err != nil {
+			return
+		}
+
+		if !s.wanted(ev.Name) {
+			continue
+		}
+
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// We are only interested in the deploymentId of the response.
+// Unfortunately, the marathon API is very inconsistent, and the
+// response we get varies.
+type Response struct {
+	DeploymentId string
+	Deployments  []struct {
+		Id string
+	}
+}
+
+// DeployApplication creates or updates an application, group or pod, and
+// returns the ID of the resulting deployment.  If force is set, any
+// existing deployment for the job is overridden.
+func (c *Client) DeployApplication(ctx context.Context, job Job, force bool) (deploymentId string, err error) {
+	return c.submit(ctx, job, force, false)
+}
+
+// DeleteApplication deletes an existing application, group or pod, and
+// returns the ID of the resulting deployment.
+func (c *Client) DeleteApplication(ctx context.Context, job Job, force bool) (deploymentId string, err error) {
+	return c.submit(ctx, job, force, true)
+}
+
+func (c *Client) submit(ctx context.Context, job Job, force, delete bool) (deploymentId string, err error) {
+
+	var jobUrl *url.URL
+
+	switch {
+	case job.IsGroup():
+		jobUrl = c.endpoint(groupPath)
+	case job.IsPod():
+		jobUrl = c.endpoint(podPath)
+	default:
+		jobUrl = c.endpoint(appPath)
+	}
+
+	// Check if we should do a POST or PUT
+	lookupUrl := *jobUrl
+	lookupUrl.Path += job.Id()
+
+	req, err := c.newRequest(ctx, "GET", &lookupUrl, nil)
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	var method string
+
+	resp, err := c.do(req, nil)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	// Existing job found, update it
+	case 200:
+		c.debugln("Existing job found")
+
+		if delete {
+			method = "DELETE"
+		} else {
+			method = "PUT"
+		}
+
+		jobUrl.Path += job.Id()
+
+		if force {
+			jobUrl.RawQuery = "force=true"
+		}
+
+	// New job
+	case 404:
+		if delete {
+			err = fmt.Errorf("Job does not exist, cannot delete. HTTP status code: %s", resp.Status)
+			return
+		}
+
+		c.debugln("Creating new job")
+		method = "POST"
+
+	// Error, abort
+	default:
+		err = fmt.Errorf("Unexpected response code. HTTP status code: %s", resp.Status)
+		return
+	}
+
+	data, err := job.Data()
+	if err != nil {
+		return
+	}
+
+	// When a conflicting deployment was first seen
+	var blocked time.Time
+
+Loop:
+	for {
+		req, err = c.newRequest(ctx, method, jobUrl, bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err = c.do(req, func() (bool, error) {
+			// Creating the job again would fail, so only retry if it
+			// wasn't created
+			status, err := c.getJSON(ctx, &lookupUrl, new(interface{}))
+			return status == 404, err
+		})
+		if _, ok := err.(*MaybeAppliedError); ok && method == "POST" {
+			id, lookupErr := c.createdDeployment(ctx, job)
+			if lookupErr != nil {
+				c.debugln("Unable to find the deployment of the failed request:", lookupErr)
+				return "", err
+			}
+			c.Logger.Println("Job was created by a request that failed, tracking its deployment", id)
+			return id, nil
+		}
+		if err != nil {
+			return
+		}
+
+		c.debugln(fmt.Sprintf("Deploy request completed. response code '%s'", resp.Status))
+
+		switch resp.StatusCode {
+
+		case 200:
+			break Loop
+
+		case 201:
+			break Loop
+
+		case 202:
+			break Loop
+
+		case 409:
+			// HTTP 409 Conflict - most likely ongoing deployment
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if blocked.IsZero() {
+				blocked = time.Now()
+			}
+
+			err = c.waitForConflict(ctx, body, blocked)
+			if err != nil {
+				return
+			}
+			continue
+
+		default:
+			break Loop
+
+		}
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode > 399 {
+		err = fmt.Errorf("ERROR - marathon returned an error response. HTTP status: %s, message: %s", resp.Status, string(body))
+		return
+	}
+
+	return deploymentIdFrom(resp, body)
+
+}
+
+// deploymentIdFrom finds the deployment ID in a response to a request
+// that started a deployment.
+func deploymentIdFrom(resp *http.Response, body []byte) (deploymentId string, err error) {
+
+	// Pod requests return the deployment ID in a header
+	if id := resp.Header.Get("Marathon-Deployment-Id"); id != "" {
+		return id, nil
+	}
+
+	var r Response
+
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case r.DeploymentId != "":
+		deploymentId = r.DeploymentId
+	case len(r.Deployments) >= 1:
+		deploymentId = r.Deployments[0].Id
+	default:
+		err = errors.New("No deployment ID detected in response")
+	}
+
+	return
+}
+
+// sendJSON makes a request with a JSON body, which may be nil, and reads
+// the response.
+func (c *Client) sendJSON(ctx context.Context, method string, u *url.URL, data []byte) (resp *http.Response, body []byte, err error) {
+
+	var r io.Reader
+	if data != nil {
+		r = bytes.NewReader(data)
+	}
+
+	req, err := c.newRequest(ctx, method, u, r)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = c.do(req, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+	return
+}
+
+// getJSON fetches u and decodes the JSON response into v.  The status
+// code is returned so callers can handle 404s themselves, v is left
+// untouched for any status other than 200.
+func (c *Client) getJSON(ctx context.Context, u *url.URL, v interface{}) (status int, err error) {
+
+	req, err := c.newRequest(ctx, "GET", u, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.do(req, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return resp.StatusCode, nil
+	}
+
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Deployments lists the deployments currently in progress.
+func (c *Client) Deployments(ctx context.Context) (list []Deployment, err error) {
+
+	status, err := c.getJSON(ctx, c.endpoint(deploymentPath), &list)
+	if err != nil {
+		return
+	}
+
+	if status != 200 {
+		err = fmt.Errorf("Unexpected response code listing deployments. HTTP status code: %d", status)
+	}
+	return
+}
@@ -1,7 +1,10 @@
-package main
+package marathon
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -54,7 +57,8 @@ func TestEventListener(t *testing.T) {
 
 	ch := make(chan RawEvent)
 
-	err := EventListener(ts.URL, ch)
+	c := testClient(ts.URL)
+	err := c.EventListener(context.Background(), ch)
 	if err != nil {
 		t.Error(err)
 	}
@@ -62,8 +66,8 @@ func TestEventListener(t *testing.T) {
 	res := <-ch
 
 	// Check that we recieved the event
-	assert.Equal(t, "api_post_event\r\n", res.Name)
-	assert.Equal(t, data+"\r\n", string(res.Data))
+	assert.Equal(t, "api_post_event", res.Name)
+	assert.Equal(t, data, string(res.Data))
 
 	// Test that we can unmarshal the event
 	e := new(Event)
@@ -78,6 +82,103 @@ func TestEventListener(t *testing.T) {
 
 }
 
+func TestEventListenerReconnect(t *testing.T) {
+	data := re.ReplaceAllString(event_tests["api_post_event"], "")
+
+	var connections int
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		connections++
+
+		w.WriteHeader(http.StatusOK)
+
+		switch connections {
+
+		// Send one event then drop the connection
+		case 1:
+			fmt.Fprintf(w, "retry: 10\r\nid: 1\r\nevent: %s\r\ndata: %s\r\n\r\n", "api_post_event", data)
+
+		// We should be told the last event seen
+		case 2:
+			if r.Header.Get("Last-Event-ID") != "1" {
+				http.Error(w, "Missing Last-Event-ID", 400)
+				return
+			}
+			fmt.Fprintf(w, "id: 2\r\nevent: %s\r\ndata: %s\r\n\r\n", "api_post_event", data)
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan RawEvent)
+
+	c := testClient(ts.URL)
+	err := c.EventListener(ctx, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := <-ch
+	assert.Equal(t, "1", res.Id)
+
+	res = <-ch
+	assert.Equal(t, ReconnectedEvent, res.Name)
+
+	res = <-ch
+	assert.Equal(t, "2", res.Id)
+
+	// The channel is closed once we stop
+	cancel()
+	for range ch {
+	}
+}
+
+func TestEventListenerFilter(t *testing.T) {
+	data := re.ReplaceAllString(event_tests["api_post_event"], "")
+
+	for _, serverFilter := range []bool{true, false} {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			types := r.URL.Query()["event_type"]
+
+			// Simulate a server that rejects the parameter
+			if !serverFilter && len(types) > 0 {
+				http.Error(w, "Unknown parameter", 400)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+
+			if len(types) == 0 {
+				fmt.Fprintf(w, "event: %s\r\ndata: %s\r\n\r\n", "api_post_event", data)
+			}
+			fmt.Fprintf(w, "event: %s\r\ndata: %s\r\n\r\n", "deployment_info", "{}")
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		ch := make(chan RawEvent)
+
+		c := testClient(ts.URL)
+		err := c.EventListener(ctx, ch, "deployment_info", "deployment_success")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := <-ch
+		assert.Equal(t, "deployment_info", res.Name)
+
+		cancel()
+		for range ch {
+		}
+		ts.Close()
+	}
+}
+
 func TestDeployApplication(t *testing.T) {
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -157,12 +258,14 @@ func TestDeployApplication(t *testing.T) {
 	}))
 	defer ts.Close()
 
+	c := testClient(ts.URL)
+
 	j, err := NewJob([]byte(testNewApp))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	id, err := DeployApplication(ts.URL, j)
+	id, err := c.DeployApplication(context.Background(), j, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -174,7 +277,7 @@ func TestDeployApplication(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	id, err = DeployApplication(ts.URL, j)
+	id, err = c.DeployApplication(context.Background(), j, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -186,7 +289,7 @@ func TestDeployApplication(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	id, err = DeployApplication(ts.URL, j)
+	id, err = c.DeployApplication(context.Background(), j, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -194,14 +297,12 @@ func TestDeployApplication(t *testing.T) {
 	assert.Equal(t, "867ed450-f6a8-4d33-9b0e-e11c5513990b", id)
 
 	// Delete application
-	delete = true
-
 	j, err = NewJob([]byte(testOldApp))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	id, err = DeployApplication(ts.URL, j)
+	id, err = c.DeleteApplication(context.Background(), j, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -209,16 +310,58 @@ func TestDeployApplication(t *testing.T) {
 	assert.Equal(t, "123099a7-c4b3-4f62-bbf0-50fde7709911", id)
 
 	// Delete non-existing application
-	// Set again for clarity
-	delete = true
-
 	j, err = NewJob([]byte(testNewApp))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = DeployApplication(ts.URL, j)
+	_, err = c.DeleteApplication(context.Background(), j, false)
 
 	assert.Error(t, err)
 
 }
+
+func testClient(rawurl string) *Client {
+	c, err := NewClient(rawurl)
+	if err != nil {
+		panic(err)
+	}
+	c.Logger = log.New(ioutil.Discard, "", 0)
+	return c
+}
+
+func TestDeployPod(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		switch {
+
+		case r.Method == "GET" && r.URL.Path == podPath+"/product/pod":
+			http.Error(w, "Not found", 404)
+
+		case r.Method == "POST" && r.URL.Path == podPath:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Marathon-Deployment-Id", "5ed4c0c5-9ff8-4a6f-a0cd-f57f59a34b43")
+			w.WriteHeader(201)
+			fmt.Fprint(w, testPod)
+
+		default:
+			http.Error(w, "Unexpected request", 500)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	j, err := NewJob([]byte(testPod))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := c.DeployApplication(context.Background(), j, false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, "5ed4c0c5-9ff8-4a6f-a0cd-f57f59a34b43", id)
+}
@@ -0,0 +1,245 @@
+package marathon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//
+// Deployments in progress
+//
+
+// conflictRetry is how long to wait before retrying a request blocked by
+// deployments Marathon didn't name, and how often to check on those it did
+// as a fallback to the event stream.
+const conflictRetry = 30 * time.Second
+
+// Deployment looks up a deployment in progress, including its plan.
+func (c *Client) Deployment(ctx context.Context, id string) (*Deployment, error) {
+
+	list, err := c.Deployments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d := findDeployment(list, id)
+	if d == nil {
+		return nil, &NotFoundError{What: "Deployment " + id}
+	}
+	return d, nil
+}
+
+// Affects lists the apps and pods changed by the deployment.
+func (d Deployment) Affects() []string {
+	return append(append([]string{}, d.AffectedApps...), d.AffectedPods...)
+}
+
+// Age is how long the deployment has been running, or zero if unknown.
+func (d Deployment) Age() time.Duration {
+	v := d.Version.Time()
+	if v.IsZero() {
+		return 0
+	}
+	return time.Since(v).Round(time.Second)
+}
+
+// Describe summarises the deployment, e.g. "deployment 1234 affecting /a,
+// /b for 4m0s, at step 1/2: ScaleApplication /a".
+func (d Deployment) Describe() string {
+
+	msg := "deployment " + d.Id
+
+	if affects := d.Affects(); len(affects) > 0 {
+		msg += " affecting " + strings.Join(affects, ", ")
+	}
+
+	if age := d.Age(); age > 0 {
+		msg += fmt.Sprintf(" for %s", age)
+	}
+
+	if d.TotalSteps > 0 {
+		msg += fmt.Sprintf(", at step %d/%d", d.CurrentStep, d.TotalSteps)
+		if len(d.CurrentActions) > 0 {
+			msg += ": " + describeActions(d.CurrentActions)
+		}
+	}
+
+	return msg
+}
+
+// conflictIds finds the IDs of the deployments blocking a request in the
+// body of a 409 response.
+func conflictIds(body []byte) []string {
+
+	var conflict struct {
+		Deployments []struct {
+			Id string
+		}
+	}
+
+	if json.Unmarshal(body, &conflict) != nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(conflict.Deployments))
+	for _, d := range conflict.Deployments {
+		ids = append(ids, d.Id)
+	}
+	return ids
+}
+
+// describeConflict explains which deployments are blocking a request,
+// given the body of the 409 response.  It is empty if Marathon didn't
+// say.
+func (c *Client) describeConflict(ctx context.Context, body []byte) string {
+
+	ids := conflictIds(body)
+	if len(ids) == 0 {
+		return ""
+	}
+
+	list, err := c.Deployments(ctx)
+	if err != nil {
+		c.debugln("Unable to look up blocking deployments:", err)
+	}
+
+	lines := make([]string, len(ids))
+	for i, id := range ids {
+		if d := findDeployment(list, id); d != nil {
+			lines[i] = "Blocked by " + d.Describe()
+		} else {
+			lines[i] = "Blocked by deployment " + id
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// waitForConflict waits for the deployments blocking a request to finish,
+// given the body of the 409 response and when the request was first
+// blocked.  An error is returned if they are still running after
+// MaxConflictWait.
+func (c *Client) waitForConflict(ctx context.Context, body []byte, blocked time.Time) error {
+
+	var deadline <-chan time.Time
+	if c.MaxConflictWait > 0 {
+		remaining := c.MaxConflictWait - time.Since(blocked)
+		if remaining <= 0 {
+			return fmt.Errorf("Conflict with existing deployment, gave up after %s", c.MaxConflictWait)
+		}
+		timer := time.NewTimer(remaining)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ids := conflictIds(body)
+
+	// Nothing to wait on, so retry blind
+	if len(ids) == 0 {
+		c.Logger.Println("Conflict with existing deployment, retry in", conflictRetry)
+
+		select {
+		case <-time.After(conflictRetry):
+		case <-deadline:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	pending := make(map[string]*Deployment)
+	for _, id := range ids {
+		pending[id] = &Deployment{Id: id}
+	}
+
+	c.checkBlocking(ctx, pending)
+
+	if len(pending) == 0 {
+		// Finished before we looked, but don't retry straight away in case
+		// Marathon hasn't caught up
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	for _, id := range ids {
+		if d, ok := pending[id]; ok {
+			c.Logger.Printf("Blocked by %s, waiting for it to finish", d.Describe())
+		}
+	}
+
+	interval := DefaultPollInterval
+	if c.Events != nil {
+		interval = conflictRetry
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	events := c.Events
+
+	for len(pending) > 0 {
+		select {
+
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+
+			switch e.Name {
+			case "deployment_success", "deployment_failed":
+				if _, ok := pending[e.DeploymentStatus.Id]; ok {
+					c.debugln("Blocking deployment", e.DeploymentStatus.Id, "finished")
+					delete(pending, e.DeploymentStatus.Id)
+				}
+			case ReconnectedEvent:
+				c.checkBlocking(ctx, pending)
+			}
+
+		case <-ticker.C:
+			c.checkBlocking(ctx, pending)
+
+		case <-deadline:
+			var lines []string
+			for _, id := range ids {
+				if d, ok := pending[id]; ok {
+					lines = append(lines, "Blocked by "+d.Describe())
+				}
+			}
+			return fmt.Errorf("Conflict with existing deployment, gave up after %s\n%s",
+				c.MaxConflictWait, strings.Join(lines, "\n"))
+
+		case <-ctx.Done():
+			return ctx.Err()
+
+		}
+	}
+
+	c.Logger.Println("Blocking deployments finished, retrying")
+	return nil
+}
+
+// checkBlocking updates the deployments blocking a request, removing any
+// that have finished.
+func (c *Client) checkBlocking(ctx context.Context, pending map[string]*Deployment) {
+
+	list, err := c.Deployments(ctx)
+	if err != nil {
+		c.debugln("Unable to look up blocking deployments:", err)
+		return
+	}
+
+	for id := range pending {
+		if d := findDeployment(list, id); d != nil {
+			pending[id] = d
+		} else {
+			delete(pending, id)
+		}
+	}
+}
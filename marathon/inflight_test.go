@@ -0,0 +1,170 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var deploymentList = `[{
+  "id": "%s",
+  "version": "%s",
+  "affectedApps": ["/a", "/b"],
+  "affectedPods": [],
+  "steps": [
+    {"actions": [{"action": "StartApplication", "app": "/a"}, {"action": "StartApplication", "app": "/b"}]},
+    {"actions": [{"action": "ScaleApplication", "app": "/a"}]}
+  ],
+  "currentActions": [{"action": "ScaleApplication", "app": "/a"}],
+  "currentStep": 2,
+  "totalSteps": 2
+}]`
+
+func deploymentServer(started time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.Method == "GET" && r.URL.Path == deploymentPath:
+			fmt.Fprintf(w, deploymentList, deploymentId, started.UTC().Format(time.RFC3339Nano))
+
+		case r.URL.Path == appPath+"/a/restart":
+			w.WriteHeader(409)
+			fmt.Fprintf(w, `{"message": "App is locked by one or more deployments.", "deployments": [{"id": "%s"}]}`, deploymentId)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+}
+
+func TestDeployment(t *testing.T) {
+
+	ts := deploymentServer(time.Now().Add(-4 * time.Minute))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+	ctx := context.Background()
+
+	d, err := c.Deployment(ctx, deploymentId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"/a", "/b"}, d.Affects())
+	assert.Len(t, d.Steps, 2)
+	assert.Len(t, d.Steps[0].Actions, 2)
+	assert.InDelta(t, 4*time.Minute, d.Age(), float64(2*time.Second))
+	assert.Equal(t, "deployment "+deploymentId+" affecting /a, /b for 4m0s, at step 2/2: ScaleApplication /a", d.Describe())
+
+	_, err = c.Deployment(ctx, "missing")
+	assert.IsType(t, &NotFoundError{}, err)
+
+	_, err = c.CancelDeployment(ctx, "missing", false)
+	assert.IsType(t, &NotFoundError{}, err)
+}
+
+func TestDeploymentConflict(t *testing.T) {
+
+	ts := deploymentServer(time.Now().Add(-4 * time.Minute))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	_, err := c.RestartApplication(context.Background(), "/a", false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Blocked by deployment "+deploymentId+" affecting /a, /b for 4m0s")
+	}
+
+	assert.Equal(t, []string{"a", "b"}, conflictIds([]byte(`{"deployments": [{"id": "a"}, {"id": "b"}]}`)))
+	assert.Empty(t, conflictIds([]byte(`Conflict`)))
+}
+
+func conflictServer(blocking *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.Method == "GET" && r.URL.Path == deploymentPath:
+			if atomic.LoadInt32(blocking) == 1 {
+				fmt.Fprint(w, `[{"id": "blocker", "affectedApps": ["/my-app"], "currentStep": 1, "totalSteps": 1}]`)
+			} else {
+				fmt.Fprint(w, `[]`)
+			}
+
+		case r.Method == "GET" && r.URL.Path == appPath+"/my-app":
+			fmt.Fprint(w, `{"app": {"id": "/my-app"}}`)
+
+		case r.Method == "PUT" && r.URL.Path == appPath+"/my-app":
+			if atomic.LoadInt32(blocking) == 1 {
+				w.WriteHeader(409)
+				fmt.Fprint(w, `{"message": "App is locked by one or more deployments.", "deployments": [{"id": "blocker"}]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"deploymentId": "%s", "version": "2017-01-01T00:00:00.000Z"}`, deploymentId)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+}
+
+func TestDeployConflictEvents(t *testing.T) {
+
+	blocking := int32(1)
+	ts := conflictServer(&blocking)
+	defer ts.Close()
+
+	events := make(chan Event, 1)
+
+	c := testClient(ts.URL)
+	c.Events = events
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		atomic.StoreInt32(&blocking, 0)
+		events <- Event{Name: "deployment_success", DeploymentStatus: DeploymentStatus{Id: "blocker"}}
+	}()
+
+	// Well within the poll interval, so the event must have been used
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	id, err := c.DeployApplication(ctx, job, false)
+	assert.NoError(t, err)
+	assert.Equal(t, deploymentId, id)
+}
+
+func TestDeployConflictMaxWait(t *testing.T) {
+
+	blocking := int32(1)
+	ts := conflictServer(&blocking)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+	c.MaxConflictWait = 200 * time.Millisecond
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.DeployApplication(context.Background(), job, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "gave up after 200ms")
+		assert.Contains(t, err.Error(), "Blocked by deployment blocker affecting /my-app, at step 1/1")
+	}
+}
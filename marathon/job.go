@@ -0,0 +1,282 @@
+package marathon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// HashLabel is the label StampHash sets to the hash of a job, so an
+// unchanged job can be detected without comparing every field.
+const HashLabel = "MARATHON_CLIENT_HASH"
+
+// Job is an application, group or pod definition to deploy.  Exactly one
+// of App, Group and Pod is set.
+type Job struct {
+	App   *App
+	Group *Group
+	Pod   *Pod
+}
+
+// NewJob parses a job file.  It is read as a group if it has any apps,
+// groups or pods, as a pod if it has containers, and as an application
+// otherwise.
+func NewJob(data []byte) (j Job, err error) {
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return
+	}
+
+	raw, ok := fields["id"]
+	if !ok {
+		err = errors.New("Missing ID")
+		return
+	}
+
+	var id string
+	if json.Unmarshal(raw, &id) != nil {
+		err = errors.New("Invalid JSON")
+		return
+	}
+	if id == "" {
+		err = errors.New("ID is empty")
+		return
+	}
+
+	_, apps := fields["apps"]
+	_, groups := fields["groups"]
+	_, pods := fields["pods"]
+
+	_, containers := fields["containers"]
+
+	switch {
+	case apps || groups || pods:
+		j.Group = new(Group)
+		err = json.Unmarshal(data, j.Group)
+	case containers:
+		j.Pod = new(Pod)
+		err = json.Unmarshal(data, j.Pod)
+	default:
+		j.App = new(App)
+		err = json.Unmarshal(data, j.App)
+	}
+	return
+}
+
+func (j Job) IsApp() bool {
+	return j.App != nil
+}
+
+func (j Job) IsGroup() bool {
+	return j.Group != nil
+}
+
+func (j Job) IsPod() bool {
+	return j.Pod != nil
+}
+
+// Id returns the absolute ID of the job.
+func (j Job) Id() string {
+	var id string
+
+	switch {
+	case j.App != nil:
+		id = j.App.Id
+	case j.Group != nil:
+		id = j.Group.Id
+	case j.Pod != nil:
+		id = j.Pod.Id
+	}
+
+	if strings.HasPrefix(id, "/") {
+		return id
+	}
+	return "/" + id
+}
+
+// Apps returns the absolute IDs of the applications in the job, including
+// those in nested groups.
+func (j Job) Apps() []string {
+	switch {
+	case j.App != nil:
+		return []string{j.Id()}
+	case j.Group != nil:
+		return groupApps(j.Group, j.Id())
+	}
+	return nil
+}
+
+func groupApps(g *Group, id string) (apps []string) {
+	if g.Apps != nil {
+		for _, app := range *g.Apps {
+			apps = append(apps, absoluteId(app.Id, id))
+		}
+	}
+	if g.Groups != nil {
+		for i := range *g.Groups {
+			sub := &(*g.Groups)[i]
+			apps = append(apps, groupApps(sub, absoluteId(sub.Id, id))...)
+		}
+	}
+	return
+}
+
+// Pods returns the absolute IDs of the pods in the job, including those in
+// nested groups.
+func (j Job) Pods() []string {
+	switch {
+	case j.Pod != nil:
+		return []string{j.Id()}
+	case j.Group != nil:
+		return groupPods(j.Group, j.Id())
+	}
+	return nil
+}
+
+func groupPods(g *Group, id string) (pods []string) {
+	if g.Pods != nil {
+		for _, pod := range *g.Pods {
+			pods = append(pods, absoluteId(pod.Id, id))
+		}
+	}
+	if g.Groups != nil {
+		for i := range *g.Groups {
+			sub := &(*g.Groups)[i]
+			pods = append(pods, groupPods(sub, absoluteId(sub.Id, id))...)
+		}
+	}
+	return
+}
+
+// absoluteId resolves an ID relative to the enclosing group.
+func absoluteId(id, parent string) string {
+	if strings.HasPrefix(id, "/") {
+		return id
+	}
+	return strings.TrimRight(parent, "/") + "/" + id
+}
+
+// Data returns the JSON definition of the job.
+func (j Job) Data() ([]byte, error) {
+	switch {
+	case j.App != nil:
+		return json.Marshal(j.App)
+	case j.Group != nil:
+		return json.Marshal(j.Group)
+	case j.Pod != nil:
+		return json.Marshal(j.Pod)
+	}
+	return nil, errors.New("Empty job")
+}
+
+// Hash returns a hash of the job definition, ignoring any hash label.
+func (j Job) Hash() (string, error) {
+
+	def, err := jobDefinition(j)
+	if err != nil {
+		return "", err
+	}
+
+	// Keys are sorted when marshalling a map
+	data, err := json.Marshal(stripHashLabels(def))
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// StampHash sets the hash label of the job.  For a group, every app and
+// pod in it is labelled with the hash of the whole group.
+func (j Job) StampHash() error {
+
+	hash, err := j.Hash()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case j.App != nil:
+		j.App.Labels = withLabel(j.App.Labels, HashLabel, hash)
+	case j.Pod != nil:
+		j.Pod.Labels = withLabel(j.Pod.Labels, HashLabel, hash)
+	case j.Group != nil:
+		stampGroup(j.Group, hash)
+	}
+	return nil
+}
+
+func stampGroup(g *Group, hash string) {
+	if g.Apps != nil {
+		for i := range *g.Apps {
+			app := &(*g.Apps)[i]
+			app.Labels = withLabel(app.Labels, HashLabel, hash)
+		}
+	}
+	if g.Pods != nil {
+		for i := range *g.Pods {
+			pod := &(*g.Pods)[i]
+			pod.Labels = withLabel(pod.Labels, HashLabel, hash)
+		}
+	}
+	if g.Groups != nil {
+		for i := range *g.Groups {
+			stampGroup(&(*g.Groups)[i], hash)
+		}
+	}
+}
+
+func withLabel(labels *map[string]string, key, value string) *map[string]string {
+	l := make(map[string]string)
+	if labels != nil {
+		for k, v := range *labels {
+			l[k] = v
+		}
+	}
+	l[key] = value
+	return &l
+}
+
+// stripHashLabels removes hash labels from a generic definition, along
+// with any labels left empty.
+func stripHashLabels(v interface{}) interface{} {
+
+	switch v := v.(type) {
+
+	case map[string]interface{}:
+		out := make(map[string]interface{})
+		for k, val := range v {
+			if k == "labels" {
+				if labels, ok := val.(map[string]interface{}); ok {
+					l := make(map[string]interface{})
+					for lk, lv := range labels {
+						if lk != HashLabel {
+							l[lk] = lv
+						}
+					}
+					if len(l) > 0 {
+						out[k] = l
+					}
+					continue
+				}
+			}
+			out[k] = stripHashLabels(val)
+		}
+		return out
+
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = stripHashLabels(v[i])
+		}
+		return out
+
+	}
+
+	return v
+}
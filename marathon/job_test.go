@@ -0,0 +1,190 @@
+package marathon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testJson = `
+{
+	"id": "/product/service/myApp",
+	"cmd": "env && sleep 300",
+	"args": ["/bin/sh", "-c", "env && sleep 300"]
+}
+`
+
+var testBadJson = `
+{
+	"cmd": "env && sleep 300",
+	"args": ["/bin/sh", "-c", "env && sleep 300"]
+}
+`
+
+var testEmptyIDJson = `
+{
+	"id": "",
+	"cmd": "env && sleep 300",
+	"args": ["/bin/sh", "-c", "env && sleep 300"]
+}
+`
+
+var testGroupJson = `
+{
+	"id": "/product",
+	"apps": [ 
+		{
+			"id":"/product/service",
+			"cmd": "env && sleep 300"
+		}
+	]
+}
+`
+
+func TestNewJob(t *testing.T) {
+
+	j, err := NewJob([]byte(testJson))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if j.IsGroup() {
+		t.Error("Should not be a group")
+	}
+
+	j, err = NewJob([]byte(testBadJson))
+	if err == nil {
+		t.Error("No error with missing ID")
+	}
+
+	j, err = NewJob([]byte(testEmptyIDJson))
+	if err == nil {
+		t.Error("No error with empty ID")
+	}
+
+	j, err = NewJob([]byte(testGroupJson))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !j.IsGroup() {
+		t.Error("Should be a group", j)
+	}
+
+}
+
+func TestNewPodJob(t *testing.T) {
+
+	j, err := NewJob([]byte(testPod))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, j.IsPod())
+	assert.False(t, j.IsGroup())
+	assert.Equal(t, "/product/pod", j.Id())
+}
+
+func TestJobId(t *testing.T) {
+
+	j, err := NewJob([]byte(`{"id": "relative/app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/relative/app", j.Id())
+
+	_, err = NewJob([]byte(`{"id": 1234}`))
+	assert.Error(t, err, "No error with a non-string ID")
+
+	// A zero job doesn't panic
+	assert.Equal(t, "/", Job{}.Id())
+}
+
+func TestGroupRoundTrip(t *testing.T) {
+
+	data := `{
+		"id": "/product",
+		"dependencies": [],
+		"groups": [{"id": "/product/nested", "apps": [], "unknownGroupField": 1}],
+		"apps": [{
+			"id": "/product/service",
+			"cmd": "env && sleep 300",
+			"instances": 0,
+			"unknownAppField": {"a": [1, 2]}
+		}]
+	}`
+
+	j, err := NewJob([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.True(t, j.IsGroup()) {
+		apps := *j.Group.Apps
+		assert.Equal(t, 0, *apps[0].Instances)
+	}
+
+	out, err := j.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, data, string(out))
+}
+
+func TestJobApps(t *testing.T) {
+
+	j, err := NewJob([]byte(testJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"/product/service/myApp"}, j.Apps())
+
+	// Relative IDs, including ones named after their group
+	j, err = NewJob([]byte(`{
+		"id": "/product/web",
+		"apps": [{"id": "web"}, {"id": "/product/web/admin"}],
+		"groups": [{"id": "api", "apps": [{"id": "api"}]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"/product/web/web", "/product/web/admin", "/product/web/api/api"}, j.Apps())
+}
+
+func TestJobHash(t *testing.T) {
+
+	j, err := NewJob([]byte(testGroupJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := j.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stamping doesn't change the hash
+	err = j.StampHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stamped, err := j.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, hash, stamped)
+	assert.Equal(t, hash, (*(*j.Group.Apps)[0].Labels)[HashLabel])
+
+	// Any other change does
+	cmd := "sleep 600"
+	(*j.Group.Apps)[0].Cmd = &cmd
+
+	changed, err := j.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEqual(t, hash, changed)
+}
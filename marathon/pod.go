@@ -0,0 +1,146 @@
+package marathon
+
+//
+// Marathon pod definitions
+//
+
+// Pod is a Marathon pod definition, a set of containers that are always
+// run together on the same agent.
+type Pod struct {
+	Id          string                `json:"id"`
+	User        *string               `json:"user,omitempty"`
+	Labels      *map[string]string    `json:"labels,omitempty"`
+	Environment *map[string]EnvVar    `json:"environment,omitempty"`
+	Containers  []PodContainer        `json:"containers"`
+	Secrets     *map[string]SecretDef `json:"secrets,omitempty"`
+	Networks    *[]PodNetwork         `json:"networks,omitempty"`
+	Scaling     *PodScaling           `json:"scaling,omitempty"`
+	Version     string                `json:"version,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (p Pod) MarshalJSON() ([]byte, error) {
+	type plain Pod
+	return marshalExtra(plain(p), p.Extra)
+}
+
+func (p *Pod) UnmarshalJSON(data []byte) error {
+	type plain Pod
+	return unmarshalExtra(data, (*plain)(p), &p.Extra)
+}
+
+type PodContainer struct {
+	Name        string             `json:"name"`
+	Resources   *PodResources      `json:"resources,omitempty"`
+	Image       *PodImage          `json:"image,omitempty"`
+	Endpoints   *[]PodEndpoint     `json:"endpoints,omitempty"`
+	Environment *map[string]EnvVar `json:"environment,omitempty"`
+	User        *string            `json:"user,omitempty"`
+	Labels      *map[string]string `json:"labels,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (c PodContainer) MarshalJSON() ([]byte, error) {
+	type plain PodContainer
+	return marshalExtra(plain(c), c.Extra)
+}
+
+func (c *PodContainer) UnmarshalJSON(data []byte) error {
+	type plain PodContainer
+	return unmarshalExtra(data, (*plain)(c), &c.Extra)
+}
+
+type PodResources struct {
+	Cpus *float64 `json:"cpus,omitempty"`
+	Mem  *float64 `json:"mem,omitempty"`
+	Disk *float64 `json:"disk,omitempty"`
+	Gpus *int     `json:"gpus,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (r PodResources) MarshalJSON() ([]byte, error) {
+	type plain PodResources
+	return marshalExtra(plain(r), r.Extra)
+}
+
+func (r *PodResources) UnmarshalJSON(data []byte) error {
+	type plain PodResources
+	return unmarshalExtra(data, (*plain)(r), &r.Extra)
+}
+
+type PodImage struct {
+	Kind      string `json:"kind"`
+	Id        string `json:"id"`
+	ForcePull *bool  `json:"forcePull,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (i PodImage) MarshalJSON() ([]byte, error) {
+	type plain PodImage
+	return marshalExtra(plain(i), i.Extra)
+}
+
+func (i *PodImage) UnmarshalJSON(data []byte) error {
+	type plain PodImage
+	return unmarshalExtra(data, (*plain)(i), &i.Extra)
+}
+
+type PodEndpoint struct {
+	Name          string             `json:"name"`
+	ContainerPort *int               `json:"containerPort,omitempty"`
+	HostPort      *int               `json:"hostPort,omitempty"`
+	Protocol      *[]string          `json:"protocol,omitempty"`
+	Labels        *map[string]string `json:"labels,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (e PodEndpoint) MarshalJSON() ([]byte, error) {
+	type plain PodEndpoint
+	return marshalExtra(plain(e), e.Extra)
+}
+
+func (e *PodEndpoint) UnmarshalJSON(data []byte) error {
+	type plain PodEndpoint
+	return unmarshalExtra(data, (*plain)(e), &e.Extra)
+}
+
+type PodNetwork struct {
+	Name   string             `json:"name,omitempty"`
+	Mode   string             `json:"mode,omitempty"`
+	Labels *map[string]string `json:"labels,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (n PodNetwork) MarshalJSON() ([]byte, error) {
+	type plain PodNetwork
+	return marshalExtra(plain(n), n.Extra)
+}
+
+func (n *PodNetwork) UnmarshalJSON(data []byte) error {
+	type plain PodNetwork
+	return unmarshalExtra(data, (*plain)(n), &n.Extra)
+}
+
+type PodScaling struct {
+	Kind         string `json:"kind,omitempty"`
+	Instances    *int   `json:"instances,omitempty"`
+	MaxInstances *int   `json:"maxInstances,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (s PodScaling) MarshalJSON() ([]byte, error) {
+	type plain PodScaling
+	return marshalExtra(plain(s), s.Extra)
+}
+
+func (s *PodScaling) UnmarshalJSON(data []byte) error {
+	type plain PodScaling
+	return unmarshalExtra(data, (*plain)(s), &s.Extra)
+}
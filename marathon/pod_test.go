@@ -0,0 +1,47 @@
+package marathon
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//
+// Tests for Marathon pod definitions
+//
+
+var testPod = `{
+	"id": "/product/pod",
+	"labels": {"team": "platform"},
+	"scaling": {"kind": "fixed", "instances": 2},
+	"networks": [{"mode": "host"}],
+	"containers": [{
+		"name": "web",
+		"resources": {"cpus": 0.1, "mem": 64},
+		"image": {"kind": "DOCKER", "id": "nginx:1.13"},
+		"endpoints": [{"name": "http", "hostPort": 0, "protocol": ["tcp"]}],
+		"healthCheck": {"http": {"endpoint": "http", "path": "/health"}}
+	}],
+	"scheduling": {"placement": {"constraints": []}}
+}`
+
+func TestPodRoundTrip(t *testing.T) {
+
+	var pod Pod
+	err := json.Unmarshal([]byte(testPod), &pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, *pod.Scaling.Instances)
+	assert.Equal(t, "nginx:1.13", pod.Containers[0].Image.Id)
+	assert.Contains(t, pod.Containers[0].Extra, "healthCheck")
+	assert.Contains(t, pod.Extra, "scheduling")
+
+	out, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, testPod, string(out))
+}
@@ -0,0 +1,185 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//
+// Track deployments by polling, for when the event stream is unusable
+//
+
+// DefaultPollInterval is used by PollDeployment when no interval is given.
+const DefaultPollInterval = 5 * time.Second
+
+// PollDeployment follows the deployment with the given ID by polling
+// /v2/deployments and the apps it affects.  It can be used in place of
+// TrackDeployment when the event stream is unavailable, and returns the
+// same results.  apps and pods are those the deployment is expected to
+// affect, which are checked if it has already finished by the first poll.
+func (c *Client) PollDeployment(ctx context.Context, id string, interval time.Duration, apps, pods []string) (duration time.Duration, err error) {
+
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	c.debugln("Polling deployment ID:", id, "every", interval)
+
+	var failures appFailures
+
+	// Human readable record of what has happened so far
+	var progress []string
+
+	// Task failures already reported, and how many, by app
+	reported := make(map[string]Timestamp)
+	taskFailures := make(map[string]int)
+
+	var step int
+
+	tracking := time.Now()
+
+	// Last time the deployment moved on a step
+	stepped := tracking
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		list, lerr := c.Deployments(ctx)
+
+		switch {
+
+		case lerr != nil && ctx.Err() == nil:
+			c.Logger.Println("Unable to poll deployments:", lerr)
+
+		case lerr != nil:
+
+		default:
+			d := findDeployment(list, id)
+
+			// Finished, check the apps came up
+			if d == nil {
+				duration = time.Since(tracking)
+				if cerr := c.checkApps(ctx, apps, pods); cerr != nil {
+					reason := cerr.Error()
+					if failures.any() {
+						reason += "\n" + failures.print()
+					}
+					return duration, fmt.Errorf("%s:\n%s", "Deployment failed", reason)
+				}
+				return duration, nil
+			}
+
+			apps = d.AffectedApps
+			pods = d.AffectedPods
+
+			if d.CurrentStep != step {
+				step = d.CurrentStep
+				msg := fmt.Sprintf("Step %d/%d: %s", d.CurrentStep, d.TotalSteps, describeActions(d.CurrentActions))
+				progress = append(progress, msg)
+				c.Logger.Println(msg)
+				stepped = time.Now()
+				failures.diagnosis = ""
+			}
+
+			if c.StallTimeout > 0 && failures.diagnosis == "" &&
+				time.Since(stepped) >= c.StallTimeout {
+				ids := append(append([]string{}, apps...), pods...)
+				c.stalled(ctx, ids, &failures)
+			}
+
+			looping := c.pollTaskFailures(ctx, apps, tracking, reported, taskFailures, &failures)
+			if looping != "" {
+				err = fmt.Errorf("Deployment failed, %s is crash looping:\n%s", looping, failures.print())
+				return time.Since(tracking), err
+			}
+
+			if failures.tooMany(c) {
+				err = fmt.Errorf("%s:\n%s", "Deployment failed, too many failures", failures.print())
+				return time.Since(tracking), err
+			}
+		}
+
+		select {
+
+		case <-ctx.Done():
+			elapsed := time.Since(tracking)
+			return elapsed, contextError(ctx, id, elapsed, progress, failures)
+
+		case <-ticker.C:
+
+		}
+	}
+}
+
+// pollTaskFailures records any task failures of apps since the deployment
+// started, and returns an app that has reached MaxTaskFailures.  Only the
+// last failure of each app is visible, so at most one is counted per poll.
+func (c *Client) pollTaskFailures(ctx context.Context, apps []string, since time.Time, reported map[string]Timestamp, counts map[string]int, failures *appFailures) (looping string) {
+
+	for _, app := range apps {
+
+		var state appState
+
+		status, err := c.getJSON(ctx, c.endpoint(appPath+app), &state)
+		if err != nil || status != 200 {
+			continue
+		}
+
+		c.debugln(fmt.Sprintf("%s %d/%d tasks running, %d healthy",
+			app, state.App.TasksRunning, state.App.Instances, state.App.TasksHealthy))
+
+		f := state.App.LastTaskFailure
+		if f == nil || f.Timestamp.Time().Before(since) || reported[app] == f.Timestamp {
+			continue
+		}
+		reported[app] = f.Timestamp
+
+		failures.add(app, fmt.Sprintf("Task %s: %s", f.State, f.Message))
+
+		c.debugln(app, "task", f.State, "on host", f.Host+":", f.Message)
+
+		// Tasks of the version being replaced don't count
+		if f.Version != state.App.Version {
+			continue
+		}
+		counts[app]++
+		if c.MaxTaskFailures > 0 && counts[app] >= c.MaxTaskFailures && looping == "" {
+			looping = app
+		}
+	}
+	return
+}
+
+func findDeployment(list []Deployment, id string) *Deployment {
+	for i := range list {
+		if list[i].Id == id {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+// describeActions summarises a list of actions, grouping the apps by
+// action, e.g. "ScaleApplication /a, /b".
+func describeActions(actions []Action) string {
+
+	var order []string
+	apps := make(map[string][]string)
+
+	for _, a := range actions {
+		if _, ok := apps[a.Action]; !ok {
+			order = append(order, a.Action)
+		}
+		apps[a.Action] = append(apps[a.Action], a.Target())
+	}
+
+	parts := make([]string, len(order))
+	for i, action := range order {
+		parts[i] = action + " " + strings.Join(apps[action], ", ")
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,167 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pollDeployments = `[{
+	"id": "867ed450-f6a8-4d33-9b0e-e11c5513990b",
+	"version": "2014-03-01T23:24:14.846Z",
+	"affectedApps": ["/my-app"],
+	"currentActions": [{"action": "ScaleApplication", "app": "/my-app"}],
+	"currentStep": 1,
+	"totalSteps": 1
+}]`
+
+func pollServer(polls int32, app string) *httptest.Server {
+
+	var count int32
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+
+		case deploymentPath:
+			// In progress for the first few polls
+			if atomic.AddInt32(&count, 1) <= polls {
+				fmt.Fprint(w, pollDeployments)
+			} else {
+				fmt.Fprint(w, "[]")
+			}
+
+		case appPath + "/my-app":
+			fmt.Fprint(w, app)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+}
+
+func TestPollDeployment(t *testing.T) {
+
+	ts := pollServer(2, `{"app": {"id": "/my-app", "instances": 2, "tasksRunning": 2, "tasksHealthy": 2}}`)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	_, err := c.PollDeployment(context.Background(), deploymentId, 10*time.Millisecond, nil, nil)
+	assert.NoError(t, err)
+}
+
+func TestPollDeploymentFailed(t *testing.T) {
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	ts := pollServer(2, `{"app": {
+		"id": "/my-app",
+		"version": "`+now+`",
+		"instances": 2,
+		"tasksRunning": 1,
+		"lastTaskFailure": {
+			"state": "TASK_FAILED",
+			"message": "Command exited with status 1",
+			"host": "slave-1234.acme.org",
+			"timestamp": "`+time.Now().Add(time.Hour).UTC().Format(time.RFC3339)+`",
+			"version": "`+now+`"
+		}
+	}}`)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	_, err := c.PollDeployment(context.Background(), deploymentId, 10*time.Millisecond, nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "1/2 tasks running")
+		assert.Contains(t, err.Error(), "Task TASK_FAILED: Command exited with status 1")
+	}
+}
+
+func TestPollDeploymentAlreadyFinished(t *testing.T) {
+
+	// Gone from /v2/deployments before the first poll
+	ts := pollServer(0, `{"app": {"id": "/my-app", "instances": 2, "tasksRunning": 0}}`)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	_, err := c.PollDeployment(context.Background(), deploymentId, 10*time.Millisecond, []string{"/my-app"}, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "0/2 tasks running")
+	}
+}
+
+func TestPollDeploymentCrashLoop(t *testing.T) {
+
+	now := time.Now().UTC()
+
+	var count int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+
+		case deploymentPath:
+			fmt.Fprint(w, pollDeployments)
+
+		// A new task failure on every poll
+		case appPath + "/my-app":
+			failed := now.Add(time.Duration(atomic.AddInt32(&count, 1)) * time.Second)
+			fmt.Fprint(w, `{"app": {
+				"id": "/my-app",
+				"version": "`+now.Format(time.RFC3339)+`",
+				"instances": 2,
+				"lastTaskFailure": {
+					"state": "TASK_FAILED",
+					"message": "Command exited with status 1",
+					"timestamp": "`+failed.Format(time.RFC3339)+`",
+					"version": "`+now.Format(time.RFC3339)+`"
+				}
+			}}`)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+	c.MaxTaskFailures = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := c.PollDeployment(ctx, deploymentId, 10*time.Millisecond, nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "/my-app is crash looping")
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+}
+
+func TestPollDeploymentTimeout(t *testing.T) {
+
+	ts := pollServer(1000, `{"app": {"id": "/my-app", "instances": 2, "tasksRunning": 2}}`)
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.PollDeployment(ctx, deploymentId, 10*time.Millisecond, nil, nil)
+
+	terr, ok := err.(*TimeoutError)
+	if assert.True(t, ok, "Expected a TimeoutError, got %v", err) {
+		assert.Equal(t, []string{"Step 1/1: ScaleApplication /my-app"}, terr.Progress)
+	}
+}
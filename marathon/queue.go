@@ -0,0 +1,175 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//
+// Diagnose deployments waiting on Mesos offers
+//
+
+// QueueItem is an app or pod waiting in the launch queue, as listed by
+// /v2/queue.
+type QueueItem struct {
+	Count int
+	Since Timestamp
+	Delay struct {
+		TimeLeftSeconds int
+		Overdue         bool
+	}
+	App struct {
+		Id string
+	}
+	Pod struct {
+		Id string
+	}
+	ProcessedOffersSummary struct {
+		ProcessedOffersCount       int
+		UnusedOffersCount          int
+		LastUnusedOfferAt          Timestamp
+		LastUsedOfferAt            Timestamp
+		RejectSummaryLastOffers    []OfferReject
+		RejectSummaryLaunchAttempt []OfferReject
+	}
+	LastUnusedOffers []struct {
+		Offer struct {
+			Hostname string
+		}
+		Timestamp Timestamp
+		Reason    []string
+	}
+}
+
+// Target returns the ID of the queued app or pod.
+func (q QueueItem) Target() string {
+	if q.Pod.Id != "" {
+		return q.Pod.Id
+	}
+	return q.App.Id
+}
+
+// OfferReject counts the offers declined for one reason.
+type OfferReject struct {
+	Reason    string
+	Declined  int
+	Processed int
+}
+
+// Human readable offer rejection reasons
+var offerReasons = map[string]string{
+	"UnfulfilledRole":                 "no resources for the role",
+	"UnfulfilledConstraint":           "constraints not matched",
+	"NoCorrespondingReservationFound": "no matching reservation",
+	"AgentMaintenance":                "agent under maintenance",
+	"InsufficientCpus":                "insufficient CPUs",
+	"InsufficientMemory":              "insufficient memory",
+	"InsufficientDisk":                "insufficient disk",
+	"InsufficientGpus":                "insufficient GPUs",
+	"InsufficientPorts":               "insufficient ports",
+	"DeclinedScarceResources":         "declined scarce resources",
+}
+
+func offerReason(reason string) string {
+	if r, ok := offerReasons[reason]; ok {
+		return r
+	}
+	return reason
+}
+
+// Queue lists the apps and pods waiting for tasks to be launched, along
+// with the offers they have recently declined.
+func (c *Client) Queue(ctx context.Context) (list []QueueItem, err error) {
+
+	u := c.endpoint(queuePath)
+	u.RawQuery = "embed=lastUnusedOffers"
+
+	var resp struct {
+		Queue []QueueItem
+	}
+
+	status, err := c.getJSON(ctx, u, &resp)
+	if err != nil {
+		return
+	}
+
+	if status != 200 {
+		err = fmt.Errorf("Unexpected response code listing the launch queue. HTTP status code: %d", status)
+	}
+	return resp.Queue, err
+}
+
+// diagnoseStall explains why a deployment of the given apps and pods has
+// made no progress for a while, using the launch queue.
+func (c *Client) diagnoseStall(ctx context.Context, ids []string, idle time.Duration) string {
+
+	str := fmt.Sprintf("Deployment stalled, no progress for %s", idle)
+
+	list, err := c.Queue(ctx)
+	if err != nil {
+		return fmt.Sprintf("%s\nUnable to check the launch queue: %s", str, err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	found := false
+	for _, q := range list {
+		if wanted[q.Target()] {
+			str += "\n" + describeQueued(q)
+			found = true
+		}
+	}
+
+	if !found {
+		str += "\nNothing waiting in the launch queue"
+	}
+	return str
+}
+
+// describeQueued summarises why a queued app or pod has not been launched.
+func describeQueued(q QueueItem) string {
+
+	s := q.ProcessedOffersSummary
+
+	str := fmt.Sprintf("%s: %d waiting, %d offers processed, %d unused",
+		q.Target(), q.Count, s.ProcessedOffersCount, s.UnusedOffersCount)
+
+	if q.Delay.TimeLeftSeconds > 0 {
+		str += fmt.Sprintf("\n  Backing off, next launch in %ds", q.Delay.TimeLeftSeconds)
+	}
+
+	for _, r := range s.RejectSummaryLastOffers {
+		if r.Declined == 0 {
+			continue
+		}
+		str += fmt.Sprintf("\n  %s: %d of %d offers declined", offerReason(r.Reason), r.Declined, r.Processed)
+	}
+
+	// Group the hosts of the last unused offers by reason
+	var order []string
+	hosts := make(map[string][]string)
+	for _, o := range q.LastUnusedOffers {
+		reasons := make([]string, len(o.Reason))
+		for i := range o.Reason {
+			reasons[i] = offerReason(o.Reason[i])
+		}
+		sort.Strings(reasons)
+		key := strings.Join(reasons, ", ")
+		if _, ok := hosts[key]; !ok {
+			order = append(order, key)
+		}
+		hosts[key] = append(hosts[key], o.Offer.Hostname)
+	}
+
+	for _, key := range order {
+		str += fmt.Sprintf("\n  Declined on %s: %s", strings.Join(hosts[key], ", "), key)
+	}
+
+	return str
+}
@@ -0,0 +1,104 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var queue = `{"queue": [{
+	"count": 2,
+	"delay": {"timeLeftSeconds": 0, "overdue": true},
+	"since": "2017-01-01T00:00:00.000Z",
+	"app": {"id": "/my-app"},
+	"processedOffersSummary": {
+		"processedOffersCount": 10,
+		"unusedOffersCount": 10,
+		"rejectSummaryLastOffers": [
+			{"reason": "UnfulfilledRole", "declined": 0, "processed": 10},
+			{"reason": "InsufficientCpus", "declined": 6, "processed": 10},
+			{"reason": "UnfulfilledConstraint", "declined": 4, "processed": 4}
+		]
+	},
+	"lastUnusedOffers": [
+		{"offer": {"hostname": "agent-1"}, "reason": ["InsufficientCpus"]},
+		{"offer": {"hostname": "agent-2"}, "reason": ["InsufficientCpus"]},
+		{"offer": {"hostname": "agent-3"}, "reason": ["UnfulfilledConstraint", "InsufficientMemory"]}
+	]
+}, {
+	"count": 1,
+	"pod": {"id": "/other-pod"}
+}]}`
+
+func queueServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.URL.Path == queuePath && r.URL.Query().Get("embed") == "lastUnusedOffers":
+			fmt.Fprint(w, queue)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+}
+
+func TestQueue(t *testing.T) {
+
+	ts := queueServer()
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	list, err := c.Queue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "/my-app", list[0].Target())
+		assert.Equal(t, "/other-pod", list[1].Target())
+		assert.Equal(t, 10, list[0].ProcessedOffersSummary.ProcessedOffersCount)
+	}
+
+	str := c.diagnoseStall(context.Background(), []string{"/my-app"}, time.Minute)
+	assert.Equal(t, `Deployment stalled, no progress for 1m0s
+/my-app: 2 waiting, 10 offers processed, 10 unused
+  insufficient CPUs: 6 of 10 offers declined
+  constraints not matched: 4 of 4 offers declined
+  Declined on agent-1, agent-2: insufficient CPUs
+  Declined on agent-3: constraints not matched, insufficient memory`, str)
+}
+
+func TestTrackDeploymentStalled(t *testing.T) {
+
+	ts := queueServer()
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+	c.StallTimeout = 10 * time.Millisecond
+
+	ch := make(chan Event, 64)
+
+	e, err := runEvent("deployment_info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch <- e
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = c.TrackDeployment(ctx, deploymentId, ch, nil, nil)
+	if assert.IsType(t, &TimeoutError{}, err) {
+		assert.Contains(t, err.Error(), "Deployment stalled")
+		assert.Contains(t, err.Error(), "insufficient CPUs: 6 of 10 offers declined")
+	}
+}
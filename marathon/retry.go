@@ -0,0 +1,258 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// Retrying requests that fail while Marathon is unavailable
+//
+
+// RetryPolicy says which failed requests are retried, and how long to wait
+// between attempts.  Network errors are always retried.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a request is made, including the
+	// first.  Zero or one means requests are never retried.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, doubling for each one
+	// after up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Jitter varies each wait by up to this fraction of it either way, so
+	// clients blocked by the same outage don't all retry at once.
+	Jitter float64
+
+	// Statuses are the HTTP status codes retried.  If the response gives
+	// a Retry-After delay it is used instead of the backoff.
+	Statuses []int
+}
+
+// DefaultRetryPolicy retries the errors seen while Marathon elects a new
+// leader, for up to about 15 seconds.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Backoff:     time.Second,
+	MaxBackoff:  10 * time.Second,
+	Jitter:      0.2,
+	Statuses:    []int{429, 502, 503, 504},
+}
+
+// retries checks if a response should be retried.
+func (p RetryPolicy) retries(status int) bool {
+	for _, s := range p.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// wait is how long to wait before the given retry, counting from 1.
+func (p RetryPolicy) wait(retry int, resp *http.Response) time.Duration {
+
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			d := time.Duration(secs) * time.Second
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				d = p.MaxBackoff
+			}
+			return d
+		}
+	}
+
+	d := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		d += time.Duration(float64(d) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+// idempotent checks if a request can be repeated without changing the
+// outcome.
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+// MaybeAppliedError is returned when a request that isn't safe to repeat
+// failed, and wasn't retried because it may have been applied anyway.
+type MaybeAppliedError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *MaybeAppliedError) Error() string {
+	return fmt.Sprintf("%s %s failed and may have been applied, so was not retried: %v", e.Method, e.URL, e.Err)
+}
+
+// do sends a request, retrying it according to the client's retry policy.
+// Requests that aren't idempotent, such as POSTs, are only retried if
+// unapplied is given, and a *MaybeAppliedError is returned if it can't
+// confirm the failed attempt had no effect.
+func (c *Client) do(req *http.Request, unapplied func() (bool, error)) (resp *http.Response, err error) {
+
+	p := c.Retry
+	ctx := req.Context()
+	refreshed := false
+
+	c.discover(ctx)
+	req.URL = c.rebase(req.URL)
+	req.Host = req.URL.Host
+
+	for attempt := 1; ; attempt++ {
+
+		if attempt > 1 {
+			req, err = c.rewind(req)
+			if err != nil {
+				return
+			}
+		}
+
+		resp, err = c.HTTPClient.Do(req)
+
+		// Expired credentials, retried once with new ones
+		if err == nil && resp.StatusCode == 401 && !refreshed && c.refresh(req) {
+			refreshed = true
+			resp.Body.Close()
+
+			req, err = c.rewind(req)
+			if err != nil {
+				return
+			}
+			resp, err = c.HTTPClient.Do(req)
+		}
+
+		var failure error
+		switch {
+		case err != nil:
+			failure = err
+		case p.retries(resp.StatusCode):
+			failure = fmt.Errorf("Got response %s", resp.Status)
+		default:
+			return
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Checked even on the last attempt, so the caller knows
+		if !idempotent(req.Method) {
+			if unapplied == nil {
+				return
+			}
+			ok, cerr := unapplied()
+			if cerr != nil || !ok {
+				if resp != nil {
+					resp.Body.Close()
+				}
+				if cerr != nil {
+					c.debugln("Unable to check if the request was applied:", cerr)
+				}
+				return nil, &MaybeAppliedError{Method: req.Method, URL: req.URL.String(), Err: failure}
+			}
+		}
+
+		// Straight on to another instance if there is one, even with no
+		// attempts left, though only once more for each instance
+		last := p.MaxAttempts
+		if last < 1 {
+			last = 1
+		}
+		moved := attempt < last+len(c.URLs)-1 && c.failover(ctx, c.instanceOf(req.URL))
+
+		if attempt >= last && !moved {
+			return
+		}
+
+		delay := p.wait(attempt, resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if moved {
+			delay = 0
+		}
+
+		c.Logger.Printf("%s %s failed: %v, retrying in %s", req.Method, req.URL.Path, failure, delay.Round(time.Millisecond))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// rewind returns a copy of a request to send again, with its body reset
+// and the latest credentials, and sent to another instance if the one in
+// use has changed.
+func (c *Client) rewind(req *http.Request) (*http.Request, error) {
+
+	again := req.Clone(req.Context())
+	again.URL = c.rebase(req.URL)
+	again.Host = again.URL.Host
+
+	err := c.authorize(again)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("Unable to retry %s %s, the body can't be resent", req.Method, req.URL)
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		again.Body = body
+	}
+
+	return again, nil
+}
+
+// createdDeployment finds the deployment started by a request to create a
+// job that failed, but which Marathon applied anyway.
+func (c *Client) createdDeployment(ctx context.Context, job Job) (deploymentId string, err error) {
+
+	list, err := c.Deployments(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, d := range list {
+		for _, id := range d.Affects() {
+			if id == job.Id() || (job.IsGroup() && isWithin(id, job.Id())) {
+				return d.Id, nil
+			}
+		}
+	}
+
+	err = fmt.Errorf("No deployment found for %s", job.Id())
+	return
+}
+
+// isWithin checks if an app or pod ID is inside a group.
+func isWithin(id, group string) bool {
+	group = strings.TrimRight(group, "/") + "/"
+	return strings.HasPrefix(id, group)
+}
@@ -0,0 +1,213 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func retryClient(rawurl string) *Client {
+	c := testClient(rawurl)
+	c.Retry = RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+		Statuses:    []int{502, 503},
+	}
+	return c
+}
+
+func TestRetryPolicyWait(t *testing.T) {
+
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.wait(1, nil))
+	assert.Equal(t, 2*time.Second, p.wait(2, nil))
+	assert.Equal(t, 4*time.Second, p.wait(3, nil))
+	assert.Equal(t, 5*time.Second, p.wait(4, nil))
+	assert.Equal(t, 5*time.Second, p.wait(40, nil))
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	assert.Equal(t, 3*time.Second, p.wait(1, resp))
+	resp.Header.Set("Retry-After", "60")
+	assert.Equal(t, 5*time.Second, p.wait(1, resp))
+
+	p.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		d := p.wait(1, nil)
+		assert.True(t, d >= 500*time.Millisecond && d <= 1500*time.Millisecond, d)
+	}
+}
+
+func TestRetryStatuses(t *testing.T) {
+
+	var calls, failures int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		switch r.URL.Path {
+
+		case deploymentPath:
+			if atomic.AddInt32(&failures, -1) >= 0 {
+				http.Error(w, "Leader unknown", 503)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[]`)
+
+		default:
+			http.Error(w, "Error", 500)
+		}
+	}))
+	defer ts.Close()
+
+	c := retryClient(ts.URL)
+	ctx := context.Background()
+
+	// Recovers within the attempts allowed
+	failures = 2
+	_, err := c.Deployments(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, calls)
+
+	// Gives up
+	calls, failures = 0, 5
+	_, err = c.Deployments(ctx)
+	assert.Error(t, err)
+	assert.EqualValues(t, 3, calls)
+
+	// Not a status to retry
+	calls = 0
+	_, err = c.AppStatus(ctx, "/my-app")
+	assert.Error(t, err)
+	assert.EqualValues(t, 1, calls)
+}
+
+func TestRetryNetworkError(t *testing.T) {
+
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	c := retryClient(ts.URL)
+
+	start := time.Now()
+	_, err := c.Deployments(context.Background())
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) >= 2*time.Millisecond)
+}
+
+func TestRetryCreate(t *testing.T) {
+
+	var posts int32
+	var created int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.Method == "GET" && r.URL.Path == appPath+"/new":
+			if atomic.LoadInt32(&created) == 0 {
+				http.Error(w, "Not found", 404)
+				return
+			}
+			fmt.Fprint(w, `{"app": {"id": "/new"}}`)
+
+		case r.Method == "POST" && r.URL.Path == appPath:
+			// The first request fails before reaching Marathon
+			if atomic.AddInt32(&posts, 1) == 1 {
+				http.Error(w, "Bad gateway", 502)
+				return
+			}
+			atomic.StoreInt32(&created, 1)
+			w.WriteHeader(201)
+			fmt.Fprintf(w, `{"deployments": [{"id": "%s"}]}`, deploymentId)
+
+		default:
+			http.Error(w, "Unexpected request", 500)
+		}
+	}))
+	defer ts.Close()
+
+	job, err := NewJob([]byte(`{"id": "/new"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := retryClient(ts.URL).DeployApplication(context.Background(), job, false)
+	assert.NoError(t, err)
+	assert.Equal(t, deploymentId, id)
+	assert.EqualValues(t, 2, posts)
+}
+
+func TestRetryCreateApplied(t *testing.T) {
+
+	var posts int32
+	var created int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.Method == "GET" && r.URL.Path == appPath+"/new":
+			if atomic.LoadInt32(&created) == 0 {
+				http.Error(w, "Not found", 404)
+				return
+			}
+			fmt.Fprint(w, `{"app": {"id": "/new"}}`)
+
+		case r.Method == "GET" && r.URL.Path == deploymentPath:
+			fmt.Fprintf(w, `[{"id": "%s", "affectedApps": ["/new"]}]`, deploymentId)
+
+		case r.Method == "POST" && r.URL.Path == appPath:
+			// Created, but the response is lost
+			atomic.AddInt32(&posts, 1)
+			atomic.StoreInt32(&created, 1)
+			http.Error(w, "Service unavailable", 503)
+
+		case r.Method == "POST" && r.URL.Path == appPath+"/new/restart":
+			atomic.AddInt32(&posts, 1)
+			http.Error(w, "Leader unknown", 503)
+
+		default:
+			http.Error(w, "Unexpected request", 500)
+		}
+	}))
+	defer ts.Close()
+
+	c := retryClient(ts.URL)
+	ctx := context.Background()
+
+	job, err := NewJob([]byte(`{"id": "/new"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Found the deployment instead of creating the app twice
+	id, err := c.DeployApplication(ctx, job, false)
+	assert.NoError(t, err)
+	assert.Equal(t, deploymentId, id)
+	assert.EqualValues(t, 1, posts)
+
+	// Also checked when there are no retries left
+	posts, created = 0, 0
+	c.Retry.MaxAttempts = 1
+
+	id, err = c.DeployApplication(ctx, job, false)
+	assert.NoError(t, err)
+	assert.Equal(t, deploymentId, id)
+	assert.EqualValues(t, 1, posts)
+
+	// Restarting twice isn't safe, so it isn't retried
+	posts = 0
+	_, err = c.RestartApplication(ctx, "/new", false)
+	assert.Error(t, err)
+	assert.EqualValues(t, 1, posts)
+}
@@ -0,0 +1,175 @@
+package marathon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+//
+// Undo failed deployments
+//
+
+// Rollback undoes a failed deployment.  If it is still in progress it is
+// cancelled, and Marathon rolls it back.  Otherwise the job is redeployed
+// at the version before the current one, or deleted if created is set,
+// meaning the job did not exist before the deployment.  The ID of the
+// rollback deployment is returned, to be tracked like any other.
+func (c *Client) Rollback(ctx context.Context, job Job, deploymentId string, created bool) (rollbackId string, err error) {
+
+	list, err := c.Deployments(ctx)
+	if err != nil {
+		return
+	}
+
+	if findDeployment(list, deploymentId) != nil {
+		c.Logger.Println("Cancelling deployment", deploymentId)
+		return c.CancelDeployment(ctx, deploymentId, false)
+	}
+
+	if created {
+		c.Logger.Println("Deleting", job.Id(), "as it was created by the failed deployment")
+		return c.DeleteApplication(ctx, job, true)
+	}
+
+	version, err := c.previousVersion(ctx, job)
+	if err != nil {
+		return
+	}
+
+	c.Logger.Println("Rolling back", job.Id(), "to version", version)
+
+	var u *url.URL
+	var data []byte
+
+	switch {
+
+	case job.IsPod():
+		// Pods can't be reverted by version, so send the old definition
+		u = c.endpoint(podPath + job.Id() + "::versions/" + version)
+
+		var status int
+		var pod Pod
+		status, err = c.getJSON(ctx, u, &pod)
+		if err != nil {
+			return
+		}
+		if status != 200 {
+			err = fmt.Errorf("Unable to fetch pod version %s. HTTP status code: %d", version, status)
+			return
+		}
+
+		data, err = json.Marshal(pod)
+		u = c.endpoint(podPath + job.Id())
+
+	case job.IsGroup():
+		u = c.endpoint(groupPath + job.Id())
+		data, err = json.Marshal(map[string]string{"version": version})
+
+	default:
+		u = c.endpoint(appPath + job.Id())
+		data, err = json.Marshal(map[string]string{"version": version})
+
+	}
+	if err != nil {
+		return
+	}
+
+	// Anything still running for the job is part of the failure
+	u.RawQuery = "force=true"
+
+	resp, body, err := c.sendJSON(ctx, "PUT", u, data)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode > 399 {
+		err = fmt.Errorf("ERROR - marathon returned an error response. HTTP status: %s, message: %s", resp.Status, string(body))
+		return
+	}
+
+	return deploymentIdFrom(resp, body)
+}
+
+// CancelDeployment stops a deployment in progress.  Unless force is set
+// Marathon starts a new deployment to roll back the changes made so far,
+// and its ID is returned.
+func (c *Client) CancelDeployment(ctx context.Context, deploymentId string, force bool) (rollbackId string, err error) {
+
+	u := c.endpoint(deploymentPath + "/" + deploymentId)
+	if force {
+		u.RawQuery = "force=true"
+	}
+
+	resp, body, err := c.sendJSON(ctx, "DELETE", u, nil)
+	if err != nil {
+		return
+	}
+
+	switch {
+
+	case resp.StatusCode == 404:
+		err = &NotFoundError{What: "Deployment " + deploymentId}
+
+	case resp.StatusCode > 399:
+		err = fmt.Errorf("ERROR - marathon returned an error response. HTTP status: %s, message: %s", resp.Status, string(body))
+
+	// Nothing to track when forced
+	case force:
+
+	default:
+		rollbackId, err = deploymentIdFrom(resp, body)
+
+	}
+
+	return
+}
+
+// previousVersion returns the version of a job before the latest one.
+func (c *Client) previousVersion(ctx context.Context, job Job) (version string, err error) {
+
+	var u *url.URL
+	var versions []string
+
+	switch {
+
+	case job.IsPod():
+		u = c.endpoint(podPath + job.Id() + "::versions")
+
+	case job.IsGroup():
+		u = c.endpoint(groupPath + job.Id() + "/versions")
+
+	default:
+		u = c.endpoint(appPath + job.Id() + "/versions")
+
+	}
+
+	var resp struct {
+		Versions []string
+	}
+
+	var status int
+	if job.IsApp() {
+		status, err = c.getJSON(ctx, u, &resp)
+		versions = resp.Versions
+	} else {
+		status, err = c.getJSON(ctx, u, &versions)
+	}
+	if err != nil {
+		return
+	}
+	if status != 200 {
+		err = fmt.Errorf("Unable to list versions of %s. HTTP status code: %d", job.Id(), status)
+		return
+	}
+
+	// Newest first, the latest being the failed one
+	if len(versions) < 2 {
+		err = errors.New("No previous version of " + job.Id() + " to roll back to, nothing was rolled back")
+		return
+	}
+
+	return versions[1], nil
+}
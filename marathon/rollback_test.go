@@ -0,0 +1,166 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollbackCancel(t *testing.T) {
+
+	var cancelled string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.Method == "GET" && r.URL.Path == deploymentPath:
+			fmt.Fprintf(w, `[{"id": "%s", "affectedApps": ["/my-app"]}]`, deploymentId)
+
+		case r.Method == "DELETE" && r.URL.Path == deploymentPath+"/"+deploymentId:
+			cancelled = r.URL.RawQuery
+			fmt.Fprint(w, `{"deploymentId": "rollback-1", "version": "2016-01-01T00:00:00.000Z"}`)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := c.Rollback(context.Background(), job, deploymentId, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "rollback-1", id)
+	assert.Equal(t, "", cancelled)
+}
+
+func TestRollbackVersion(t *testing.T) {
+
+	var body string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.Method == "GET" && r.URL.Path == deploymentPath:
+			fmt.Fprint(w, "[]")
+
+		case r.Method == "GET" && r.URL.Path == appPath+"/my-app/versions":
+			fmt.Fprint(w, `{"versions": ["2016-01-02T00:00:00.000Z", "2016-01-01T00:00:00.000Z"]}`)
+
+		case r.Method == "PUT" && r.URL.Path == appPath+"/my-app" && r.URL.RawQuery == "force=true":
+			data, _ := ioutil.ReadAll(r.Body)
+			body = string(data)
+			fmt.Fprint(w, `{"deploymentId": "rollback-2", "version": "2016-01-03T00:00:00.000Z"}`)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := c.Rollback(context.Background(), job, deploymentId, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "rollback-2", id)
+	assert.JSONEq(t, `{"version": "2016-01-01T00:00:00.000Z"}`, body)
+}
+
+func TestRollbackNoPreviousVersion(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+
+		case deploymentPath:
+			fmt.Fprint(w, "[]")
+
+		case groupPath + "/product/versions":
+			fmt.Fprint(w, `["2016-01-02T00:00:00.000Z"]`)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/product", "apps": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Rollback(context.Background(), job, deploymentId, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "nothing was rolled back")
+	}
+}
+
+func TestRollbackCreated(t *testing.T) {
+
+	var deleted string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.Method == "GET" && r.URL.Path == deploymentPath:
+			fmt.Fprint(w, "[]")
+
+		case r.Method == "GET" && r.URL.Path == appPath+"/my-app":
+			fmt.Fprint(w, `{"app": {"id": "/my-app"}}`)
+
+		case r.Method == "DELETE" && r.URL.Path == appPath+"/my-app":
+			deleted = r.URL.RawQuery
+			fmt.Fprint(w, `{"deploymentId": "rollback-3", "version": "2016-01-03T00:00:00.000Z"}`)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/my-app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first deploy of the app failed, so there is nothing to go back to
+	id, err := c.Rollback(context.Background(), job, deploymentId, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "rollback-3", id)
+	assert.Equal(t, "force=true", deleted)
+}
@@ -0,0 +1,136 @@
+package marathon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+//
+// Scaling and restarting running apps
+//
+
+// ScaleApplication changes the number of instances of an application, and
+// returns the ID of the resulting deployment.  If force is set, any
+// existing deployment for the app is overridden.  A NotFoundError is
+// returned if there is no such app, which is checked first as Marathon
+// would create one.
+func (c *Client) ScaleApplication(ctx context.Context, id string, instances int, force bool) (deploymentId string, err error) {
+
+	if instances < 0 {
+		err = fmt.Errorf("Invalid number of instances: %d", instances)
+		return
+	}
+
+	data, err := json.Marshal(map[string]int{"instances": instances})
+	if err != nil {
+		return
+	}
+
+	id = absoluteId(id, "")
+
+	var state appState
+	err = c.getStatus(ctx, c.endpoint(appPath+id), "Application "+id, &state)
+	if err != nil {
+		return
+	}
+
+	return c.startDeployment(ctx, "PUT", c.endpoint(appPath+id), "Application "+id, force, data)
+}
+
+// ScaleGroup multiplies the number of instances of every app in a group by
+// factor, and returns the ID of the resulting deployment.
+func (c *Client) ScaleGroup(ctx context.Context, id string, factor float64, force bool) (deploymentId string, err error) {
+
+	if factor < 0 {
+		err = fmt.Errorf("Invalid scale factor: %g", factor)
+		return
+	}
+
+	data, err := json.Marshal(map[string]float64{"scaleBy": factor})
+	if err != nil {
+		return
+	}
+
+	id = absoluteId(id, "")
+	return c.startDeployment(ctx, "PUT", c.endpoint(groupPath+id), "Group "+id, force, data)
+}
+
+// ScalePod changes the number of instances of a pod, and returns the ID of
+// the resulting deployment.  Pods can only be updated as a whole, so the
+// live definition is sent back with the new count.
+func (c *Client) ScalePod(ctx context.Context, id string, instances int, force bool) (deploymentId string, err error) {
+
+	if instances < 0 {
+		err = fmt.Errorf("Invalid number of instances: %d", instances)
+		return
+	}
+
+	id = absoluteId(id, "")
+
+	var pod Pod
+	err = c.getStatus(ctx, c.endpoint(podPath+id), "Pod "+id, &pod)
+	if err != nil {
+		return
+	}
+
+	if pod.Scaling == nil {
+		pod.Scaling = &PodScaling{Kind: "fixed"}
+	}
+	pod.Scaling.Instances = &instances
+	pod.Version = ""
+
+	data, err := json.Marshal(pod)
+	if err != nil {
+		return
+	}
+
+	return c.startDeployment(ctx, "PUT", c.endpoint(podPath+id), "Pod "+id, force, data)
+}
+
+// RestartApplication replaces every task of an application with a new
+// one, following its upgrade strategy, and returns the ID of the resulting
+// deployment.
+func (c *Client) RestartApplication(ctx context.Context, id string, force bool) (deploymentId string, err error) {
+	id = absoluteId(id, "")
+	return c.startDeployment(ctx, "POST", c.endpoint(appPath+id+"/restart"), "Application "+id, force, nil)
+}
+
+// startDeployment makes a request that starts a deployment of something
+// that already exists, and returns the deployment ID.  A NotFoundError is
+// returned if what doesn't exist.
+func (c *Client) startDeployment(ctx context.Context, method string, u *url.URL, what string, force bool, data []byte) (deploymentId string, err error) {
+
+	if force {
+		u.RawQuery = "force=true"
+	}
+
+	resp, body, err := c.sendJSON(ctx, method, u, data)
+	if err != nil {
+		return
+	}
+
+	c.debugln(fmt.Sprintf("%s request completed. response code '%s'", method, resp.Status))
+
+	switch {
+
+	case resp.StatusCode == 404:
+		err = &NotFoundError{What: what}
+		return
+
+	case resp.StatusCode == 409:
+		err = fmt.Errorf("Conflict with existing deployment, use force to override. HTTP status code: %s", resp.Status)
+		if blocking := c.describeConflict(ctx, body); blocking != "" {
+			err = fmt.Errorf("%s\n%s", err, blocking)
+		}
+		return
+
+	case resp.StatusCode > 399:
+		err = fmt.Errorf("ERROR - marathon returned an error response. HTTP status: %s, message: %s", resp.Status, string(body))
+		return
+
+	}
+
+	return deploymentIdFrom(resp, body)
+}
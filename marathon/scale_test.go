@@ -0,0 +1,121 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaleApplication(t *testing.T) {
+
+	var body, query string
+	var created bool
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.Method == "GET" && r.URL.Path == appPath+"/my-app":
+			fmt.Fprint(w, `{"app": {"id": "/my-app", "instances": 1}}`)
+
+		case r.Method == "PUT" && r.URL.Path == appPath+"/my-app":
+			data, _ := ioutil.ReadAll(r.Body)
+			body = string(data)
+			query = r.URL.RawQuery
+			fmt.Fprintf(w, `{"deploymentId": "%s", "version": "2017-01-01T00:00:00.000Z"}`, deploymentId)
+
+		// Marathon creates apps that don't exist on PUT
+		case r.Method == "PUT":
+			created = true
+			w.WriteHeader(201)
+			fmt.Fprintf(w, `{"deploymentId": "%s", "version": "2017-01-01T00:00:00.000Z"}`, deploymentId)
+
+		case r.Method == "POST" && r.URL.Path == appPath+"/my-app/restart":
+			query = r.URL.RawQuery
+			fmt.Fprintf(w, `{"deploymentId": "%s", "version": "2017-01-01T00:00:00.000Z"}`, deploymentId)
+
+		case r.URL.Path == appPath+"/busy-app/restart":
+			http.Error(w, `{"message": "App is locked by one or more deployments."}`, 409)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+	ctx := context.Background()
+
+	id, err := c.ScaleApplication(ctx, "my-app", 3, false)
+	assert.NoError(t, err)
+	assert.Equal(t, deploymentId, id)
+	assert.JSONEq(t, `{"instances": 3}`, body)
+	assert.Equal(t, "", query)
+
+	id, err = c.RestartApplication(ctx, "/my-app", true)
+	assert.NoError(t, err)
+	assert.Equal(t, deploymentId, id)
+	assert.Equal(t, "force=true", query)
+
+	_, err = c.RestartApplication(ctx, "/busy-app", false)
+	assert.Error(t, err)
+
+	_, err = c.ScaleApplication(ctx, "/missing", 1, false)
+	assert.IsType(t, &NotFoundError{}, err)
+	assert.False(t, created, "Scaling created a missing app")
+}
+
+func TestScaleGroupAndPod(t *testing.T) {
+
+	var body, query string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+
+		case r.Method == "PUT" && (r.URL.Path == groupPath+"/product" || r.URL.Path == podPath+"/product/pod"):
+			data, _ := ioutil.ReadAll(r.Body)
+			body = string(data)
+			query = r.URL.RawQuery
+			fmt.Fprintf(w, `{"deploymentId": "%s", "version": "2017-01-01T00:00:00.000Z"}`, deploymentId)
+
+		case r.Method == "GET" && r.URL.Path == podPath+"/product/pod":
+			fmt.Fprint(w, `{"id": "/product/pod", "containers": [{"name": "web"}], "scaling": {"kind": "fixed", "instances": 1}, "version": "2017-01-01T00:00:00.000Z"}`)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+	ctx := context.Background()
+
+	id, err := c.ScaleGroup(ctx, "/product", 1.5, true)
+	assert.NoError(t, err)
+	assert.Equal(t, deploymentId, id)
+	assert.JSONEq(t, `{"scaleBy": 1.5}`, body)
+	assert.Equal(t, "force=true", query)
+
+	id, err = c.ScalePod(ctx, "product/pod", 4, false)
+	assert.NoError(t, err)
+	assert.Equal(t, deploymentId, id)
+	assert.Contains(t, body, `"scaling":{"kind":"fixed","instances":4}`)
+	assert.NotContains(t, body, `"version"`)
+
+	_, err = c.ScaleGroup(ctx, "/product", -1, false)
+	assert.Error(t, err)
+
+	_, err = c.ScalePod(ctx, "/missing", 1, false)
+	assert.IsType(t, &NotFoundError{}, err)
+
+	_, err = c.ScaleApplication(ctx, "/missing", 1, false)
+	assert.IsType(t, &NotFoundError{}, err)
+}
@@ -0,0 +1,241 @@
+package marathon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// Smoke tests run against the tasks of a deployment
+//
+
+// SmokeChecksKey is the field of a job file holding its smoke checks.  It
+// is removed before the job is sent to Marathon.
+const SmokeChecksKey = "smokeChecks"
+
+// SmokeCheck is an HTTP or TCP check run against every running task of an
+// app once it has been deployed.
+type SmokeCheck struct {
+	// App to check, relative to the job.  Empty for the job itself, or
+	// every app in a group.
+	App string `json:"app,omitempty"`
+
+	// HTTP (the default) or TCP
+	Protocol string `json:"protocol,omitempty"`
+
+	// Path requested by HTTP checks
+	Path string `json:"path,omitempty"`
+
+	// Index of the task port to check
+	PortIndex int `json:"portIndex,omitempty"`
+
+	// HTTP status codes that pass, any 2xx or 3xx if empty
+	StatusCodes []int `json:"statusCodes,omitempty"`
+
+	TimeoutSeconds  int `json:"timeoutSeconds,omitempty"`
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+
+	// Further attempts made before the check fails
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+func (s SmokeCheck) timeout() time.Duration {
+	if s.TimeoutSeconds > 0 {
+		return time.Duration(s.TimeoutSeconds) * time.Second
+	}
+	return 5 * time.Second
+}
+
+func (s SmokeCheck) interval() time.Duration {
+	if s.IntervalSeconds > 0 {
+		return time.Duration(s.IntervalSeconds) * time.Second
+	}
+	return 2 * time.Second
+}
+
+func (s SmokeCheck) passes(status int) bool {
+	if len(s.StatusCodes) == 0 {
+		return status >= 200 && status < 400
+	}
+	for _, code := range s.StatusCodes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the check, e.g. "HTTP /health on port index 0".
+func (s SmokeCheck) String() string {
+	if strings.ToUpper(s.Protocol) == "TCP" {
+		return fmt.Sprintf("TCP on port index %d", s.PortIndex)
+	}
+	return fmt.Sprintf("HTTP %s on port index %d", s.Path, s.PortIndex)
+}
+
+// SplitSmokeChecks removes the smoke checks from a job file, returning
+// them along with the rest of the job.  A sidecar file holding only smoke
+// checks is read the same way.
+func SplitSmokeChecks(data []byte) (checks []SmokeCheck, job []byte, err error) {
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return
+	}
+
+	raw, ok := fields[SmokeChecksKey]
+	if !ok {
+		return nil, data, nil
+	}
+
+	err = json.Unmarshal(raw, &checks)
+	if err != nil {
+		err = errors.New("Invalid smoke checks: " + err.Error())
+		return
+	}
+
+	for _, check := range checks {
+		switch strings.ToUpper(check.Protocol) {
+		case "", "HTTP", "TCP":
+		default:
+			err = errors.New("Invalid smoke check protocol: " + check.Protocol)
+			return
+		}
+	}
+
+	delete(fields, SmokeChecksKey)
+	job, err = json.Marshal(fields)
+	return
+}
+
+// SmokeTest runs the checks against every running task of the apps in a
+// job, retrying each as configured, and returns an error describing any
+// that failed.  Pods are not checked.
+func (c *Client) SmokeTest(ctx context.Context, job Job, checks []SmokeCheck) error {
+
+	var problems []string
+
+	for _, check := range checks {
+
+		apps := job.Apps()
+		if check.App != "" {
+			apps = []string{absoluteId(check.App, job.Id())}
+		}
+
+		for _, app := range apps {
+
+			tasks, err := c.Tasks(ctx, app)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("Application: %s\nUnable to list tasks: %s", app, err))
+				continue
+			}
+
+			tested := 0
+
+			for _, task := range tasks {
+
+				if task.State != "" && task.State != "TASK_RUNNING" {
+					continue
+				}
+				tested++
+
+				if check.PortIndex >= len(task.Ports) {
+					problems = append(problems, fmt.Sprintf("Application: %s\nTask %s has no port index %d",
+						app, task.Id, check.PortIndex))
+					continue
+				}
+
+				addr := net.JoinHostPort(task.Host, strconv.Itoa(task.Ports[check.PortIndex]))
+
+				err = c.smokeTask(ctx, check, addr)
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("Application: %s\nTask %s %s failed: %s",
+						app, task.Id, check, err))
+				}
+			}
+
+			if tested == 0 {
+				problems = append(problems, fmt.Sprintf("Application: %s\nNo running tasks to check", app))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s:\n%s", "Smoke tests failed", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// smokeTask runs a check against one task, retrying until it passes or
+// runs out of attempts.
+func (c *Client) smokeTask(ctx context.Context, check SmokeCheck, addr string) (err error) {
+
+	for attempt := 0; ; attempt++ {
+
+		err = runSmokeCheck(ctx, check, addr)
+		if err == nil {
+			c.debugln("Smoke check", check, "passed on", addr)
+			return
+		}
+
+		if attempt >= check.MaxRetries {
+			return
+		}
+
+		c.debugln("Smoke check", check, "failed on", addr+":", err)
+
+		select {
+		case <-time.After(check.interval()):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func runSmokeCheck(ctx context.Context, check SmokeCheck, addr string) error {
+
+	ctx, cancel := context.WithTimeout(ctx, check.timeout())
+	defer cancel()
+
+	if strings.ToUpper(check.Protocol) == "TCP" {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	path := check.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+addr+path, nil)
+	if err != nil {
+		return err
+	}
+
+	// Not the Marathon client, the tasks don't want its credentials
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if !check.passes(resp.StatusCode) {
+		return errors.New("Got response " + resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitSmokeChecks(t *testing.T) {
+
+	checks, job, err := SplitSmokeChecks([]byte(`{
+		"id": "/my-app",
+		"smokeChecks": [{"path": "/health", "maxRetries": 3}, {"protocol": "TCP", "portIndex": 1}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []SmokeCheck{{Path: "/health", MaxRetries: 3}, {Protocol: "TCP", PortIndex: 1}}, checks)
+	assert.JSONEq(t, `{"id": "/my-app"}`, string(job))
+
+	// Jobs without any are left alone
+	data := []byte(`{"id": "/my-app", "cmd": "sleep 30"}`)
+	checks, job, err = SplitSmokeChecks(data)
+	assert.NoError(t, err)
+	assert.Nil(t, checks)
+	assert.Equal(t, data, job)
+
+	_, _, err = SplitSmokeChecks([]byte(`{"smokeChecks": [{"protocol": "UDP"}]}`))
+	assert.Error(t, err)
+}
+
+func TestSmokeTest(t *testing.T) {
+
+	// A task that is healthy after the first request
+	var requests int
+	task := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/health" || requests < 2 {
+			http.Error(w, "Unavailable", 503)
+		}
+	}))
+	defer task.Close()
+
+	tu, _ := url.Parse(task.URL)
+	host, port, _ := net.SplitHostPort(tu.Host)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+
+		case appPath + "/product/web/tasks":
+			fmt.Fprintf(w, `{"tasks": [
+				{"id": "web.1", "host": "%s", "ports": [%s], "state": "TASK_RUNNING"},
+				{"id": "web.0", "host": "%s", "ports": [1], "state": "TASK_KILLED"}
+			]}`, host, port, host)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+
+	job, err := NewJob([]byte(`{"id": "/product", "apps": [{"id": "web"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []SmokeCheck{
+		{Path: "/health", MaxRetries: 1, IntervalSeconds: 0},
+		{Protocol: "TCP"},
+	}
+
+	err = c.SmokeTest(context.Background(), job, checks)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+
+	// Out of retries
+	checks = []SmokeCheck{{App: "web", Path: "/missing"}}
+
+	err = c.SmokeTest(context.Background(), job, checks)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Task web.1 HTTP /missing on port index 0 failed: Got response 503")
+	}
+
+	// Unknown port
+	checks = []SmokeCheck{{Protocol: "TCP", PortIndex: 1}}
+
+	err = c.SmokeTest(context.Background(), job, checks)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Task web.1 has no port index 1")
+	}
+}
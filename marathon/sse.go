@@ -0,0 +1,179 @@
+package marathon
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+)
+
+//
+// text/event-stream decoding
+//
+
+// EventDecoder reads events from a text/event-stream, as described in
+// https://html.spec.whatwg.org/multipage/server-sent-events.html
+type EventDecoder struct {
+	r *bufio.Reader
+
+	// Set after a CR, so a following LF is not read as an empty line
+	skipLF bool
+	// Set once the first line, which may have a byte order mark, is read
+	started bool
+
+	lastId string
+	retry  time.Duration
+
+	name string
+	data bytes.Buffer
+}
+
+// NewEventDecoder returns a decoder reading from r.
+func NewEventDecoder(r io.Reader) *EventDecoder {
+	return &EventDecoder{r: bufio.NewReader(r)}
+}
+
+// LastEventId returns the last event ID sent by the server.
+func (d *EventDecoder) LastEventId() string {
+	return d.lastId
+}
+
+// Retry returns the reconnection time sent by the server, or zero if none
+// has been sent.
+func (d *EventDecoder) Retry() time.Duration {
+	return d.retry
+}
+
+// Decode returns the next complete event.  Comments and events without
+// data are skipped, and an event cut short by the end of the stream is
+// discarded.  The error is io.EOF if the stream ended cleanly.
+func (d *EventDecoder) Decode() (ev RawEvent, err error) {
+
+	for {
+
+		line, err := d.readLine()
+		if err != nil {
+			return ev, err
+		}
+
+		// Blank line, dispatch the event
+		if len(line) == 0 {
+
+			if d.data.Len() == 0 {
+				d.name = ""
+				continue
+			}
+
+			ev.Id = d.lastId
+			ev.Name = d.name
+			if ev.Name == "" {
+				ev.Name = "message"
+			}
+
+			// Drop the final newline
+			ev.Data = make([]byte, d.data.Len()-1)
+			copy(ev.Data, d.data.Bytes())
+
+			d.name = ""
+			d.data.Reset()
+
+			return ev, nil
+		}
+
+		// Comment, used for heartbeats
+		if line[0] == ':' {
+			continue
+		}
+
+		var field, value []byte
+
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field = line[:i]
+			value = line[i+1:]
+			if len(value) > 0 && value[0] == ' ' {
+				value = value[1:]
+			}
+		} else {
+			field = line
+		}
+
+		switch string(field) {
+
+		case "event":
+			d.name = string(value)
+
+		case "data":
+			d.data.Write(value)
+			d.data.WriteByte('\n')
+
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				d.lastId = string(value)
+			}
+
+		case "retry":
+			if isDigits(value) {
+				ms, err := strconv.Atoi(string(value))
+				if err == nil {
+					d.retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+
+		}
+	}
+}
+
+// readLine returns the next line without its terminator, which may be
+// CRLF, LF or CR.
+func (d *EventDecoder) readLine() (line []byte, err error) {
+
+	defer func() {
+		if !d.started && err == nil {
+			d.started = true
+			// Skip a UTF-8 byte order mark
+			line = bytes.TrimPrefix(line, []byte("\xef\xbb\xbf"))
+		}
+	}()
+
+	for {
+
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		if d.skipLF {
+			d.skipLF = false
+			if b == '\n' {
+				continue
+			}
+		}
+
+		switch b {
+
+		case '\r':
+			d.skipLF = true
+			return line, nil
+
+		case '\n':
+			return line, nil
+
+		default:
+			line = append(line, b)
+
+		}
+	}
+}
+
+func isDigits(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for i := range b {
+		if b[i] < '0' || b[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
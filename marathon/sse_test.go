@@ -0,0 +1,139 @@
+package marathon
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//
+// Tests for text/event-stream decoding
+//
+
+func decodeAll(t *testing.T, stream string) (events []RawEvent, d *EventDecoder) {
+	d = NewEventDecoder(strings.NewReader(stream))
+	for {
+		ev, err := d.Decode()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+}
+
+func TestEventDecoder(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		stream string
+		events []RawEvent
+	}{
+		{
+			name:   "LF line endings",
+			stream: "event: a\ndata: 1\n\nevent: b\ndata: 2\n\n",
+			events: []RawEvent{{Name: "a", Data: []byte("1")}, {Name: "b", Data: []byte("2")}},
+		},
+		{
+			name:   "CRLF line endings",
+			stream: "event: a\r\ndata: 1\r\n\r\n",
+			events: []RawEvent{{Name: "a", Data: []byte("1")}},
+		},
+		{
+			name:   "CR line endings",
+			stream: "event: a\rdata: 1\r\r",
+			events: []RawEvent{{Name: "a", Data: []byte("1")}},
+		},
+		{
+			name:   "Multi-line data",
+			stream: "event: a\ndata: {\ndata:  \"x\": 1\ndata: }\n\n",
+			events: []RawEvent{{Name: "a", Data: []byte("{\n \"x\": 1\n}")}},
+		},
+		{
+			name:   "No space after colon",
+			stream: "event:a\ndata:1\n\n",
+			events: []RawEvent{{Name: "a", Data: []byte("1")}},
+		},
+		{
+			name:   "Default event name",
+			stream: "data: 1\n\n",
+			events: []RawEvent{{Name: "message", Data: []byte("1")}},
+		},
+		{
+			name:   "Comments and heartbeats",
+			stream: ": heartbeat\n\n\r\n:\nevent: a\n: inline\ndata: 1\n\n",
+			events: []RawEvent{{Name: "a", Data: []byte("1")}},
+		},
+		{
+			name:   "Event without data is not dispatched",
+			stream: "event: a\n\ndata: 1\n\n",
+			events: []RawEvent{{Name: "message", Data: []byte("1")}},
+		},
+		{
+			name:   "Empty data field",
+			stream: "event: a\ndata\n\n",
+			events: []RawEvent{{Name: "a", Data: []byte("")}},
+		},
+		{
+			name:   "Unknown fields are ignored",
+			stream: "event: a\nfoo: bar\ndata: 1\n\n",
+			events: []RawEvent{{Name: "a", Data: []byte("1")}},
+		},
+		{
+			name:   "Event IDs persist",
+			stream: "id: 7\nevent: a\ndata: 1\n\nevent: b\ndata: 2\n\n",
+			events: []RawEvent{{Id: "7", Name: "a", Data: []byte("1")}, {Id: "7", Name: "b", Data: []byte("2")}},
+		},
+		{
+			name:   "Incomplete event is discarded",
+			stream: "event: a\ndata: 1\n\nevent: b\ndata: 2\n",
+			events: []RawEvent{{Name: "a", Data: []byte("1")}},
+		},
+		{
+			name:   "Byte order mark",
+			stream: "\xef\xbb\xbfevent: a\ndata: 1\n\n",
+			events: []RawEvent{{Name: "a", Data: []byte("1")}},
+		},
+	}
+
+	for _, test := range tests {
+		events, _ := decodeAll(t, test.stream)
+		assert.Equal(t, test.events, events, test.name)
+	}
+}
+
+func TestEventDecoderFields(t *testing.T) {
+
+	_, d := decodeAll(t, "retry: 2500\nid: abc\n\n")
+	assert.Equal(t, 2500*time.Millisecond, d.Retry())
+	assert.Equal(t, "abc", d.LastEventId())
+
+	// Invalid values are ignored
+	_, d = decodeAll(t, "retry: 2500\nretry: 10s\nid: abc\nid: a\x00b\n\n")
+	assert.Equal(t, 2500*time.Millisecond, d.Retry())
+	assert.Equal(t, "abc", d.LastEventId())
+
+	// An empty ID resets it
+	_, d = decodeAll(t, "id: abc\nid\n\n")
+	assert.Equal(t, "", d.LastEventId())
+}
+
+func TestEventDecoderMarathon(t *testing.T) {
+
+	data := re.ReplaceAllString(event_tests["api_post_event"], "")
+
+	events, _ := decodeAll(t, "\r\n\r\nevent: api_post_event\r\ndata: "+data+"\r\n\r\n")
+	if assert.Len(t, events, 1) {
+		e := new(Event)
+		err := e.Unmarshal(events[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "0:0:0:0:0:0:0:1", e.ApiPostEvent.ClientIp)
+	}
+}
@@ -0,0 +1,141 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+//
+// Current state of apps, pods and their tasks
+//
+
+// AppStatus is the running state of an application.
+type AppStatus struct {
+	Id              string
+	Version         Timestamp
+	Instances       int
+	TasksStaged     int
+	TasksRunning    int
+	TasksHealthy    int
+	TasksUnhealthy  int
+	Deployments     []struct{ Id string }
+	LastTaskFailure *TaskFailure
+}
+
+// TaskFailure is the last task of an app to fail.
+type TaskFailure struct {
+	AppId     string
+	TaskId    string
+	State     string
+	Message   string
+	Host      string
+	Timestamp Timestamp
+	Version   Timestamp
+}
+
+// PodStatus is the running state of a pod, from /v2/pods/{id}::status
+type PodStatus struct {
+	Id        string
+	Status    string
+	Instances []struct {
+		Id            string
+		Status        string
+		AgentHostname string
+	}
+}
+
+// Task is a running, or recently finished, task of an application.
+type Task struct {
+	Id                 string
+	AppId              string
+	Host               string
+	Ports              []int
+	State              string
+	Version            Timestamp
+	StartedAt          Timestamp
+	HealthCheckResults []struct {
+		Alive bool
+	}
+}
+
+// Healthy checks if the task is passing all its health checks, which it is
+// if it has none.
+func (t Task) Healthy() bool {
+	for _, r := range t.HealthCheckResults {
+		if !r.Alive {
+			return false
+		}
+	}
+	return true
+}
+
+// AppStatus looks up the state of an application.
+func (c *Client) AppStatus(ctx context.Context, id string) (*AppStatus, error) {
+	id = absoluteId(id, "")
+	var state appState
+	err := c.getStatus(ctx, c.endpoint(appPath+id), "Application "+id, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &state.App, nil
+}
+
+// PodStatus looks up the state of a pod.
+func (c *Client) PodStatus(ctx context.Context, id string) (*PodStatus, error) {
+	id = absoluteId(id, "")
+	var state PodStatus
+	err := c.getStatus(ctx, c.endpoint(podPath+id+"::status"), "Pod "+id, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Tasks lists the tasks of an application.
+func (c *Client) Tasks(ctx context.Context, appId string) ([]Task, error) {
+	appId = absoluteId(appId, "")
+	var list struct {
+		Tasks []Task
+	}
+	err := c.getStatus(ctx, c.endpoint(appPath+appId+"/tasks"), "Application "+appId, &list)
+	return list.Tasks, err
+}
+
+// Group looks up a group definition.
+func (c *Client) Group(ctx context.Context, id string) (*Group, error) {
+	id = absoluteId(id, "")
+	var group Group
+	err := c.getStatus(ctx, c.endpoint(groupPath+id), "Group "+id, &group)
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// NotFoundError is returned when looking up something that doesn't exist.
+type NotFoundError struct {
+	What string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.What + " not found"
+}
+
+func (c *Client) getStatus(ctx context.Context, u *url.URL, what string, v interface{}) error {
+
+	status, err := c.getJSON(ctx, u, v)
+	switch {
+
+	case err != nil:
+		return err
+
+	case status == 404:
+		return &NotFoundError{What: what}
+
+	case status != 200:
+		return fmt.Errorf("Unable to look up %s. HTTP status code: %d", what, status)
+
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusRelativeIds(t *testing.T) {
+
+	var paths []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		paths = append(paths, r.URL.Path)
+
+		switch r.URL.Path {
+
+		case appPath + "/my-app":
+			fmt.Fprint(w, `{"app": {"id": "/my-app", "instances": 1}}`)
+
+		case appPath + "/my-app/tasks":
+			fmt.Fprint(w, `{"tasks": [{"id": "my-app.1", "appId": "/my-app"}]}`)
+
+		case podPath + "/my-pod::status":
+			fmt.Fprint(w, `{"id": "/my-pod", "status": "STABLE"}`)
+
+		case groupPath + "/product":
+			fmt.Fprint(w, `{"id": "/product"}`)
+
+		default:
+			http.Error(w, "Not found", 404)
+		}
+	}))
+	defer ts.Close()
+
+	c := testClient(ts.URL)
+	ctx := context.Background()
+
+	app, err := c.AppStatus(ctx, "my-app")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/my-app", app.Id)
+	}
+
+	tasks, err := c.Tasks(ctx, "my-app")
+	if assert.NoError(t, err) {
+		assert.Len(t, tasks, 1)
+	}
+
+	pod, err := c.PodStatus(ctx, "my-pod")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "STABLE", pod.Status)
+	}
+
+	group, err := c.Group(ctx, "product")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/product", group.Id)
+	}
+
+	// Absolute IDs are left alone
+	_, err = c.AppStatus(ctx, "/my-app")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		appPath + "/my-app",
+		appPath + "/my-app/tasks",
+		podPath + "/my-pod::status",
+		groupPath + "/product",
+		appPath + "/my-app",
+	}, paths)
+}
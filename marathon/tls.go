@@ -0,0 +1,156 @@
+package marathon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+//
+// Connecting to Marathon over TLS
+//
+
+// TLSOptions configures how the client connects to Marathon over HTTPS.
+type TLSOptions struct {
+	// CA is a PEM file of certificates, or a directory of them, to trust
+	// as well as the system's.
+	CA string
+
+	// CertFile and KeyFile are a client certificate to present, for
+	// Marathon behind mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// MinVersion is the lowest TLS version to use, such as
+	// tls.VersionTLS12.  Zero uses the Go default.
+	MinVersion uint16
+
+	// ServerName overrides the name sent with SNI and checked against the
+	// server certificate, for when Marathon is reached by another name.
+	ServerName string
+
+	// Insecure skips verifying the server certificate.  Only for lab
+	// clusters, as anyone in the way can read the credentials.
+	Insecure bool
+}
+
+// Config builds the TLS configuration for the options.
+func (o TLSOptions) Config() (*tls.Config, error) {
+
+	cfg := &tls.Config{
+		MinVersion:         o.MinVersion,
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.Insecure,
+	}
+
+	if o.CA != "" {
+		pool, err := loadCA(o.CA)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	switch {
+
+	case o.CertFile != "" && o.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+
+	case o.CertFile != "" || o.KeyFile != "":
+		return nil, errors.New("A client certificate needs both a certificate and a key file")
+
+	}
+
+	return cfg, nil
+}
+
+// loadCA adds the certificates in a PEM file, or every file in a
+// directory, to the system pool.
+func loadCA(path string) (*x509.CertPool, error) {
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read CA certificates: %v", err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA certificates: %v", err)
+		}
+		files = files[:0]
+		for _, e := range entries {
+			if e.Mode().IsRegular() {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	found := false
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA certificates: %v", err)
+		}
+		if pool.AppendCertsFromPEM(data) {
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("No CA certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// ParseTLSVersion reads a TLS version such as "1.2".
+func ParseTLSVersion(s string) (uint16, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("Unknown TLS version %s, use 1.0, 1.1, 1.2 or 1.3", s)
+}
+
+// SetTLS configures the transport used for every request to Marathon,
+// including the event stream.
+func (c *Client) SetTLS(o TLSOptions) error {
+
+	cfg, err := o.Config()
+	if err != nil {
+		return err
+	}
+
+	if o.Insecure {
+		c.Logger.Println("Warning: not verifying the TLS certificate of Marathon")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	c.HTTPClient.Transport = transport
+
+	return nil
+}
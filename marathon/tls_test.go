@@ -0,0 +1,133 @@
+package marathon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tlsServer runs a fake Marathon over TLS, with a certificate for
+// example.com and 127.0.0.1.
+func tlsServer(clientAuth tls.ClientAuthType) *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[]`)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: clientAuth, MaxVersion: tls.VersionTLS12}
+	ts.StartTLS()
+	return ts
+}
+
+// writePEM writes a PEM block to name in dir.
+func writePEM(t *testing.T, dir, name, kind string, der []byte) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tlsClient connects to ts with the given options, and makes a request.
+func tlsClient(ts *httptest.Server, o TLSOptions) error {
+	c := testClient(ts.URL)
+	c.Retry.MaxAttempts = 1
+	err := c.SetTLS(o)
+	if err != nil {
+		return err
+	}
+	_, err = c.Deployments(context.Background())
+	return err
+}
+
+func TestTLSTrust(t *testing.T) {
+
+	ts := tlsServer(tls.NoClientCert)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := writePEM(t, dir, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+
+	assert.Error(t, tlsClient(ts, TLSOptions{}))
+	assert.NoError(t, tlsClient(ts, TLSOptions{CA: ca}))
+	assert.NoError(t, tlsClient(ts, TLSOptions{CA: dir}))
+	assert.NoError(t, tlsClient(ts, TLSOptions{Insecure: true}))
+
+	// Reached by another name
+	assert.NoError(t, tlsClient(ts, TLSOptions{CA: ca, ServerName: "example.com"}))
+	assert.Error(t, tlsClient(ts, TLSOptions{CA: ca, ServerName: "marathon.example.org"}))
+
+	// The server only goes up to TLS 1.2
+	assert.NoError(t, tlsClient(ts, TLSOptions{CA: ca, MinVersion: tls.VersionTLS12}))
+	assert.Error(t, tlsClient(ts, TLSOptions{CA: ca, MinVersion: tls.VersionTLS13}))
+
+	empty := filepath.Join(dir, "empty")
+	assert.NoError(t, os.Mkdir(empty, 0700))
+	assert.Error(t, tlsClient(ts, TLSOptions{CA: empty}))
+	assert.Error(t, tlsClient(ts, TLSOptions{CA: filepath.Join(dir, "missing.pem")}))
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+
+	ts := tlsServer(tls.RequireAnyClientCert)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "deployer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &testKey.PublicKey, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := writePEM(t, dir, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+	cert := writePEM(t, dir, "client.pem", "CERTIFICATE", der)
+	key := writePEM(t, dir, "client.key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testKey))
+
+	assert.Error(t, tlsClient(ts, TLSOptions{CA: ca}))
+	assert.NoError(t, tlsClient(ts, TLSOptions{CA: ca, CertFile: cert, KeyFile: key}))
+	assert.Error(t, tlsClient(ts, TLSOptions{CA: ca, CertFile: cert}))
+}
+
+func TestParseTLSVersion(t *testing.T) {
+
+	v, err := ParseTLSVersion("1.2")
+	assert.NoError(t, err)
+	assert.EqualValues(t, tls.VersionTLS12, v)
+
+	v, err = ParseTLSVersion("")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, v)
+
+	_, err = ParseTLSVersion("1.4")
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/nutmegdevelopment/marathon-client/marathon"
+)
+
+//
+// Scaling and restarting apps, pods and groups, and cancelling deployments
+//
+
+func runScale(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	trk := trackingFlags(fs)
+	var group bool
+	fs.BoolVar(&group, "group", false, "Scale every app in a group, by the factor given instead of a number of instances")
+	fs.Parse(args)
+
+	a := arguments(fs, 2)
+
+	if group {
+		factor, err := strconv.ParseFloat(a[1], 64)
+		if err != nil {
+			log.Fatal("Invalid scale factor: ", a[1])
+		}
+
+		runTracked(conn, trk, "Scale", func(ctx context.Context, client *marathon.Client) (string, marathon.Job, error) {
+			id, err := client.ScaleGroup(ctx, a[0], factor, trk.force)
+			return id, marathon.Job{}, err
+		})
+		return
+	}
+
+	instances, err := strconv.Atoi(a[1])
+	if err != nil {
+		log.Fatal("Invalid number of instances: ", a[1])
+	}
+
+	runTracked(conn, trk, "Scale", func(ctx context.Context, client *marathon.Client) (string, marathon.Job, error) {
+		id, err := client.ScaleApplication(ctx, a[0], instances, trk.force)
+		if _, ok := err.(*marathon.NotFoundError); !ok {
+			return id, marathon.Job{App: &marathon.App{Id: a[0]}}, err
+		}
+		id, err = client.ScalePod(ctx, a[0], instances, trk.force)
+		if _, ok := err.(*marathon.NotFoundError); ok {
+			err = fmt.Errorf("No application or pod found with ID %s", a[0])
+		}
+		return id, marathon.Job{Pod: &marathon.Pod{Id: a[0]}}, err
+	})
+}
+
+func runRestart(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	trk := trackingFlags(fs)
+	fs.Parse(args)
+
+	a := arguments(fs, 1)
+
+	runTracked(conn, trk, "Restart", func(ctx context.Context, client *marathon.Client) (string, marathon.Job, error) {
+		id, err := client.RestartApplication(ctx, a[0], trk.force)
+		return id, marathon.Job{App: &marathon.App{Id: a[0]}}, err
+	})
+}
+
+func runCancel(name string, args []string) {
+	fs := newFlagSet(name)
+	conn := connectionFlags(fs)
+	var noRollback, wait bool
+	fs.BoolVar(&noRollback, "no-rollback", false, "Stop the deployment where it is, leaving any changes it has made")
+	fs.BoolVar(&wait, "wait", false, "Track the rollback until it finishes")
+	fs.Parse(args)
+
+	id := arguments(fs, 1)[0]
+
+	if wait && !noRollback {
+		trk := &tracking{mode: "auto", pollInterval: marathon.DefaultPollInterval}
+		runTracked(conn, trk, "Rollback", func(ctx context.Context, client *marathon.Client) (string, marathon.Job, error) {
+			rollbackId, err := client.CancelDeployment(ctx, id, false)
+			return rollbackId, marathon.Job{}, err
+		})
+		return
+	}
+
+	client := conn.client()
+
+	ctx, cancel := conn.withTimeout(context.Background())
+	defer cancel()
+
+	rollbackId, err := client.CancelDeployment(ctx, id, noRollback)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if noRollback {
+		log.Println("Deployment", id, "stopped")
+	} else {
+		log.Println("Deployment", id, "cancelled, rolling back with deployment", rollbackId)
+	}
+}
+
+// runTracked starts a deployment and tracks it, exiting with status 1 if
+// it fails.  start returns the deployment ID and what it affects, if known.
+func runTracked(conn *connection, trk *tracking, what string, start func(context.Context, *marathon.Client) (string, marathon.Job, error)) {
+
+	client := conn.client()
+	trk.configure(client)
+
+	root, stop := conn.signalContext()
+	defer stop()
+
+	ctx, cancel := conn.withTimeout(root)
+	defer cancel()
+
+	t := trk.start(ctx, client)
+
+	id, job, err := start(ctx, client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dur, err := t.track(ctx, id, job)
+	if !report(what, dur, err) {
+		cancel()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/nutmegdevelopment/marathon-client/marathon"
+)
+
+//
+// Tracking deployments started by a command
+//
+
+// tracking holds the flags of commands that start a deployment.
+type tracking struct {
+	force        bool
+	mode         string
+	pollInterval time.Duration
+	maxFailures  int
+	maxTasks     int
+	stallTimeout time.Duration
+}
+
+func trackingFlags(fs *flag.FlagSet) *tracking {
+	t := new(tracking)
+	fs.BoolVar(&t.force, "force", false, "Force deploy over any existing deployments")
+	fs.StringVar(&t.mode, "track", "auto", "How to track the deployment: events, poll, or auto to poll if the event stream is unavailable")
+	fs.DurationVar(&t.pollInterval, "poll-interval", marathon.DefaultPollInterval, "Interval between polls when tracking by polling")
+	fs.IntVar(&t.maxFailures, "max-failures", 0, "Fail the deployment once this many failures, such as failed health checks, are seen (0 for no limit)")
+	fs.IntVar(&t.maxTasks, "max-task-failures", 0, "Fail the deployment once an app has this many tasks fail, to catch crash loops (0 for no limit)")
+	fs.DurationVar(&t.stallTimeout, "stall-timeout", 2*time.Minute, "Check the launch queue if the deployment makes no progress for this long (0 to never check)")
+	return t
+}
+
+// configure checks the flags and applies them to the client.
+func (t *tracking) configure(client *marathon.Client) {
+
+	if t.mode != "auto" && t.mode != "events" && t.mode != "poll" {
+		log.Fatal("Tracking mode (-track) must be one of auto, events or poll")
+	}
+
+	client.MaxFailures = t.maxFailures
+	client.MaxTaskFailures = t.maxTasks
+	client.StallTimeout = t.stallTimeout
+}
+
+// tracker follows deployments on the event stream, or by polling.
+type tracker struct {
+	client   *marathon.Client
+	events   chan marathon.Event
+	poll     bool
+	interval time.Duration
+
+	// Closes the event stream
+	stop context.CancelFunc
+}
+
+// start opens the event stream, unless tracking by polling.  It must be
+// called before starting a deployment so no events are missed.
+func (t *tracking) start(ctx context.Context, client *marathon.Client) *tracker {
+
+	tr := &tracker{
+		client:   client,
+		poll:     t.mode == "poll",
+		interval: t.pollInterval,
+	}
+
+	ctx, tr.stop = context.WithCancel(ctx)
+
+	if tr.poll {
+		return tr
+	}
+
+	rawEvents := make(chan marathon.RawEvent, 64)
+	tr.events = make(chan marathon.Event, 64)
+
+	// Start listening for events
+	err := client.EventListener(ctx, rawEvents, marathon.DeploymentEvents...)
+	switch {
+
+	case err != nil && t.mode == "auto":
+		log.Println("Event stream unavailable, tracking by polling:", err)
+		tr.poll = true
+
+	case err != nil:
+		log.Fatal(err)
+
+	default:
+		// Run the event bus
+		go client.EventBus(ctx, rawEvents, tr.events)
+	}
+
+	return tr
+}
+
+// track follows a deployment of job until it finishes.  The job's apps
+// are checked if the deployment finishes before it can be followed.
+func (t *tracker) track(ctx context.Context, id string, job marathon.Job) (time.Duration, error) {
+	if t.poll {
+		return t.client.PollDeployment(ctx, id, t.interval, job.Apps(), job.Pods())
+	}
+	return t.client.TrackDeployment(ctx, id, t.events, job.Apps(), job.Pods())
+}
+
+// report logs the outcome of a deployment, and whether it succeeded.
+func report(what string, dur time.Duration, err error) bool {
+
+	switch err.(type) {
+	case nil:
+		log.Println(what, "succeeded")
+	case *marathon.TimeoutError:
+		log.Println(what, "timed out")
+	default:
+		log.Println(what, "failed")
+	}
+
+	log.Printf("%s: %6.2f %s\n", "Duration", dur.Seconds(), "seconds")
+
+	if err != nil {
+		log.Println("Reason:", err)
+	}
+	return err == nil
+}